@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"mibk.dev/phpfmt/format"
+)
+
+// A cache remembers which files are already formatted, so that they
+// need not be formatted again. Each such file is recorded as an empty
+// file in the cache directory, named after the hash of its content and
+// everything else the formatted code depends on: the phpfmt binary,
+// the options, and the PHP version. Changing any of them makes phpfmt
+// use different entries.
+//
+// The cache is best-effort; errors only cause it not to be used.
+// A nil *cache is not used.
+type cache struct {
+	dir string
+
+	once     sync.Once
+	binaryID []byte // the hash of the phpfmt executable; nil if unknown
+}
+
+// userCache returns the cache in the user's cache directory,
+// or nil if it cannot be used or -cache is false.
+func userCache() *cache {
+	if !*useCache {
+		return nil
+	}
+	dir, err := os.UserCacheDir() // $XDG_CACHE_HOME on Unix
+	if err != nil {
+		return nil
+	}
+	return &cache{dir: filepath.Join(dir, "phpfmt")}
+}
+
+func (c *cache) hashBinary() {
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return
+	}
+	c.binaryID = h.Sum(nil)
+}
+
+// key returns the name of the cache entry for the source code src
+// formatted according to cfg. It returns "" if the cache cannot be used.
+func (c *cache) key(src []byte, cfg format.Config) string {
+	if c == nil || len(cfg.Lines) > 0 {
+		return ""
+	}
+	c.once.Do(c.hashBinary)
+	if c.binaryID == nil {
+		return ""
+	}
+	h := sha256.New()
+	h.Write(c.binaryID)
+	fmt.Fprintf(h, "\x00options %d\x00php %d\x00maxwidth %d\x00", cfg.Options, cfg.PHP, cfg.MaxWidth)
+	h.Write(src)
+	sum := hex.EncodeToString(h.Sum(nil))
+	return filepath.Join(c.dir, sum[:2], sum)
+}
+
+// isFormatted reports whether the cache says that the code
+// with the key is formatted.
+func isFormatted(key string) bool {
+	if key == "" {
+		return false
+	}
+	_, err := os.Stat(key)
+	return err == nil
+}
+
+// markFormatted records in the cache that the code
+// with the key is formatted.
+func markFormatted(key string) {
+	if key == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(key), 0o777); err != nil {
+		return
+	}
+	os.WriteFile(key, nil, 0o666)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"mibk.dev/phpfmt/format"
+	"mibk.dev/phpfmt/naive"
+	"mibk.dev/phpfmt/phpver"
+)
+
+func TestCacheKey(t *testing.T) {
+	c := &cache{dir: t.TempDir()}
+	src := []byte("<?php\n\necho 1;\n")
+	base := format.Config{Options: naive.Standard, PHP: phpver.PHP80}
+	key := c.key(src, base)
+	if key == "" {
+		t.Skip("cache unavailable")
+	}
+	for name, k := range map[string]string{
+		"content": c.key([]byte("<?php\n\necho 2;\n"), base),
+		"options": c.key(src, format.Config{Options: naive.Standard | naive.Simplify, PHP: phpver.PHP80}),
+		"php":     c.key(src, format.Config{Options: naive.Standard, PHP: phpver.PHP74}),
+	} {
+		if k == key {
+			t.Errorf("changing %s does not change the key", name)
+		}
+	}
+	if k := c.key(src, format.Config{Options: naive.Standard, PHP: phpver.PHP80, Filename: "x.php"}); k != key {
+		t.Errorf("the file name changes the key")
+	}
+	if k := c.key(src, format.Config{Options: naive.Standard, PHP: phpver.PHP80, Lines: []format.LineRange{{Start: 1, End: 1}}}); k != "" {
+		t.Errorf("got key %q for a line range, want none", k)
+	}
+
+	if isFormatted(key) {
+		t.Fatal("empty cache reports a formatted file")
+	}
+	markFormatted(key)
+	if !isFormatted(key) {
+		t.Error("formatted file not remembered")
+	}
+}
@@ -2,13 +2,17 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"sync"
 
 	"mibk.dev/phpfmt/naive"
+	"mibk.dev/phpfmt/phpver"
 )
 
 var defaultOptions naive.Options
@@ -30,77 +34,182 @@ func init() {
 	}
 }
 
-const (
-	defaultPHPVersion = 50400
-	targetPHPVersion  = 80000
-)
+const defaultPHPVersion = phpver.PHP54
+
+// A phpVersion is the PHP version targeted by a project.
+type phpVersion struct {
+	id     phpver.Version
+	source string // where the version was found
+}
+
+func (v phpVersion) String() string {
+	return fmt.Sprintf("PHP %v (%s)", v.id, v.source)
+}
 
-var minVerCache = map[string]int{}
+var (
+	minVerMu    sync.Mutex
+	minVerCache = map[string]phpVersion{} // guarded by minVerMu
+)
 
-func findMinPHPVersion(dir string) (minVer int, error error) {
+// findMinPHPVersion returns the minimum PHP version targeted by
+// the Composer project dir belongs to. The warnings about the Composer
+// files are written to log when the files are first read.
+// It is safe for concurrent use.
+func findMinPHPVersion(log io.Writer, dir string) (phpVersion, error) {
 	dir, err := filepath.Abs(dir)
 	if err != nil {
-		return 0, err
+		return phpVersion{}, err
 	}
 
-	for parent, ver := range minVerCache {
-		if _, err := filepath.Rel(parent, dir); err == nil {
+	// Remember all the directories on the way up,
+	// so that their siblings don't need to look again.
+	var visited []string
+	for {
+		minVerMu.Lock()
+		ver, ok := minVerCache[dir]
+		minVerMu.Unlock()
+		var warns []error
+		if !ok {
+			b, err := os.ReadFile(filepath.Join(dir, "composer.json"))
+			switch {
+			case err == nil:
+				if ver, warns, err = projectPHPVersion(dir, b); err != nil {
+					return phpVersion{}, err
+				}
+				ok = true
+			case !os.IsNotExist(err):
+				return phpVersion{}, err
+			case filepath.Dir(dir) == dir:
+				// Root.
+				ver, ok = phpVersion{defaultPHPVersion, "default"}, true
+			}
+		}
+		visited = append(visited, dir)
+		if ok {
+			minVerMu.Lock()
+			if v, ok := minVerCache[dir]; ok {
+				// Found concurrently; it has been warned about.
+				ver, warns = v, nil
+			}
+			for _, d := range visited {
+				minVerCache[d] = ver
+			}
+			minVerMu.Unlock()
+			for _, w := range warns {
+				fmt.Fprintf(log, "phpfmt: warning: %v\n", w)
+			}
 			return ver, nil
 		}
+		dir = filepath.Dir(dir)
 	}
+}
 
-	var b []byte
-	for dir != "" {
-		if ver, ok := minVerCache[dir]; ok {
-			return ver, nil
+// forgetPHPVersions removes the cached PHP versions of dir and all
+// its subdirectories, e.g. after a Composer file in dir has changed.
+func forgetPHPVersions(dir string) {
+	minVerMu.Lock()
+	defer minVerMu.Unlock()
+	for d := range minVerCache {
+		if inDir(d, dir) {
+			delete(minVerCache, d)
 		}
+	}
+}
 
-		comp := filepath.Join(dir, "composer.json")
-		b, err = os.ReadFile(comp)
-		if os.IsNotExist(err) {
-			old := dir
-			dir = filepath.Dir(dir)
-			if dir != old {
-				continue
-			}
-			// Root.
-			return defaultPHPVersion, nil
-		}
-		if err != nil {
-			return 0, err
+// inDir reports whether path is dir or lies within it.
+func inDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// projectPHPVersion determines the PHP version targeted by the
+// Composer project in dir, given the contents of its composer.json.
+// The first of these settings that is found is used:
+//
+//  1. config.platform.php in composer.json,
+//  2. platform-overrides.php in composer.lock,
+//  3. require.php in composer.json,
+//  4. platform.php in composer.lock.
+//
+// The platform settings hold an exact version, which Composer uses
+// instead of the one it runs on. The require settings hold a version
+// constraint, whose lowest satisfying version is used. A setting with
+// an empty or invalid version is skipped, as if it were missing,
+// and it is reported in warns.
+func projectPHPVersion(dir string, composerJSON []byte) (ver phpVersion, warns []error, err error) {
+	jsonName := filepath.Join(dir, "composer.json")
+	var proj struct {
+		Require json.RawMessage
+		Config  struct {
+			Platform json.RawMessage
 		}
-		break
 	}
-
-	if len(b) == 0 {
-		return defaultPHPVersion, nil
+	if err := json.Unmarshal(composerJSON, &proj); err != nil {
+		return phpVersion{}, nil, fmt.Errorf("%s: %v", jsonName, err)
 	}
 
-	defer func() {
-		minVerCache[dir] = minVer
-	}()
-
-	var proj struct {
-		Require map[string]string
+	lockName := filepath.Join(dir, "composer.lock")
+	var lock struct {
+		Platform          json.RawMessage
+		PlatformOverrides json.RawMessage `json:"platform-overrides"`
 	}
-	if err := json.Unmarshal(b, &proj); err != nil {
-		return 0, err
+	b, err := os.ReadFile(lockName)
+	if err == nil {
+		err = json.Unmarshal(b, &lock)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return phpVersion{}, nil, fmt.Errorf("%s: %v", lockName, err)
 	}
 
-	ver, ok := strings.CutPrefix(proj.Require["php"], ">=")
-	if !ok {
-		if ver, ok = strings.CutPrefix(proj.Require["php"], "^"); !ok {
-			return defaultPHPVersion, nil
+	for _, src := range []struct {
+		file, key  string
+		packages   json.RawMessage
+		constraint bool
+	}{
+		{jsonName, "config.platform.php", proj.Config.Platform, false},
+		{lockName, "platform-overrides.php", lock.PlatformOverrides, false},
+		{jsonName, "require.php", proj.Require, true},
+		{lockName, "platform.php", lock.Platform, true},
+	} {
+		v, ok := platformPackage(src.packages, "php")
+		if !ok {
+			continue
+		}
+		ver = phpVersion{source: fmt.Sprintf("%s in %s", src.key, src.file)}
+		var id int
+		if strings.TrimSpace(v) == "" {
+			err = errors.New("empty version")
+		} else if src.constraint {
+			id, err = minConstraintVersion(v)
+		} else {
+			var parts []int
+			parts, _, err = parseConstraintVersion(v)
+			id = versionID(parts)
+		}
+		if err != nil {
+			// Don't fail on every file of the project.
+			warns = append(warns, fmt.Errorf("%s: %s: %v; ignoring it", src.file, src.key, err))
+			continue
 		}
+		ver.id = phpver.Version(id)
+		if ver.id == 0 {
+			// Any version goes.
+			ver.id = defaultPHPVersion
+		}
+		return ver, warns, nil
 	}
+	return phpVersion{defaultPHPVersion, "default"}, warns, nil
+}
 
-	ver = strings.TrimSpace(ver)
-	maj, min, ok := strings.Cut(ver, ".")
-	if !ok {
-		return defaultPHPVersion, nil
+// platformPackage returns the version of the package name in
+// packages, a JSON object mapping package names to versions.
+// Composer writes an empty list instead of an empty object,
+// and uses false to disable a platform package.
+func platformPackage(packages json.RawMessage, name string) (string, bool) {
+	var m map[string]any
+	if json.Unmarshal(packages, &m) != nil {
+		return "", false
 	}
-
-	majInt, _ := strconv.Atoi(maj)
-	minInt, _ := strconv.Atoi(min)
-	return majInt*10000 + minInt*100, nil
+	v, ok := m[name].(string)
+	return v, ok
 }
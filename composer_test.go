@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mibk.dev/phpfmt/phpver"
+)
+
+func TestProjectPHPVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		json, lock string
+		want       phpver.Version
+		wantSource string
+		wantWarns  int
+	}{
+		{"nothing", `{}`, ``, defaultPHPVersion, "default", 0},
+		{"require", `{"require": {"php": "^7.4 || ^8.0"}}`, ``, 70400, "require.php", 0},
+		{
+			"platform",
+			`{"require": {"php": ">=7.2"}, "config": {"platform": {"php": "8.1.2"}}}`,
+			`{"platform": {"php": ">=7.2"}, "platform-overrides": {"php": "8.0"}}`,
+			80102, "config.platform.php", 0,
+		},
+		{
+			"lock overrides",
+			`{"require": {"php": ">=7.2"}}`,
+			`{"platform": {"php": ">=7.2"}, "platform-overrides": {"php": "8.0"}}`,
+			80000, "platform-overrides.php", 0,
+		},
+		{"lock platform", `{}`, `{"platform": {"php": "^8.2"}, "platform-overrides": []}`, 80200, "platform.php", 0},
+		{"empty require", `{"require": {"php": ""}}`, ``, defaultPHPVersion, "default", 1},
+		{"invalid require", `{"require": {"php": ">=foo"}}`, `{"platform": {"php": "^8.1"}}`, 80100, "platform.php", 1},
+		{"disabled platform", `{"config": {"platform": {"php": false}}}`, `{"platform": []}`, defaultPHPVersion, "default", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.lock != "" {
+				if err := os.WriteFile(filepath.Join(dir, "composer.lock"), []byte(tt.lock), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ver, warns, err := projectPHPVersion(dir, []byte(tt.json))
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if len(warns) != tt.wantWarns {
+				t.Errorf("got warnings %v, want %d", warns, tt.wantWarns)
+			}
+			if ver.id != tt.want {
+				t.Errorf("got version %d, want %d", ver.id, tt.want)
+			}
+			if got, _, _ := strings.Cut(ver.source, " in "); got != tt.wantSource {
+				t.Errorf("got source %q, want %q", ver.source, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestFindMinPHPVersionWarning(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "composer.json"), []byte(`{"require": {"php": ">=foo"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(dir, "sub")
+	for i, d := range []string{dir, sub} {
+		var log strings.Builder
+		ver, err := findMinPHPVersion(&log, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ver.id != defaultPHPVersion {
+			t.Errorf("%s: got version %d, want %d", d, ver.id, defaultPHPVersion)
+		}
+		want := ""
+		if i == 0 {
+			want = "phpfmt: warning: " + filepath.Join(dir, "composer.json") + `: require.php: invalid version constraint ">=foo": invalid version "foo"; ignoring it` + "\n"
+		}
+		if got := log.String(); got != want {
+			t.Errorf("%s: got log %q, want %q", d, got, want)
+		}
+	}
+}
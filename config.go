@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"mibk.dev/phpfmt/internal/ignore"
+	"mibk.dev/phpfmt/naive"
+	"mibk.dev/phpfmt/phpver"
+)
+
+// configName is the name of the project configuration file.
+// Its settings apply to the directory it is in and all its
+// subdirectories, unless overridden by another such file.
+const configName = ".phpfmt.json"
+
+// configFile is the content of a configuration file.
+// Settings that are left out are inherited from the configuration
+// file in a parent directory, or from the defaults.
+type configFile struct {
+	// Root stops looking for configuration files in parent directories.
+	Root bool `json:"root"`
+
+	TrailingComma     *bool `json:"trailingComma"`
+	AlignColumns      *bool `json:"alignColumns"`
+	LowercaseKeywords *bool `json:"lowercaseKeywords"`
+	Simplify          *bool `json:"simplify"`
+	CollapseLists     *bool `json:"collapseLists"`
+
+	// MaxWidth is the maximum width of lines; 0 means no limit.
+	MaxWidth *int `json:"maxWidth"`
+
+	// PHP overrides the PHP version found in composer.json.
+	PHP string `json:"php"`
+
+	// Exclude lists .gitignore-style patterns relative to the
+	// directory of the configuration file.
+	Exclude []string `json:"exclude"`
+}
+
+// A projectConfig is the effective configuration for a directory.
+type projectConfig struct {
+	opts     naive.Options
+	maxWidth int
+	php      phpVersion // if unset, the version is found in composer.json
+	exclude  ignore.Stack
+}
+
+var (
+	configMu    sync.Mutex
+	configCache = map[string]configResult{} // guarded by configMu
+)
+
+// A configResult is a cached result of loadConfig. Errors are cached
+// too, so that a broken configuration file is read only once.
+type configResult struct {
+	c   *projectConfig
+	err error
+}
+
+// findConfig returns the configuration for files in dir.
+// It is safe for concurrent use.
+func findConfig(dir string) (*projectConfig, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	return loadConfig(dir)
+}
+
+// forgetConfigs removes the cached configurations of dir and all
+// its subdirectories, e.g. after the configuration file in dir has changed.
+func forgetConfigs(dir string) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	for d := range configCache {
+		if inDir(d, dir) {
+			delete(configCache, d)
+		}
+	}
+}
+
+func loadConfig(dir string) (*projectConfig, error) {
+	if r, ok := configCache[dir]; ok {
+		return r.c, r.err
+	}
+	c, err := readConfig(dir)
+	configCache[dir] = configResult{c, err}
+	return c, err
+}
+
+// readConfig reads the configuration for dir, loading
+// the configurations of the parent directories as needed.
+func readConfig(dir string) (*projectConfig, error) {
+	parent := filepath.Dir(dir)
+	isRoot := parent == dir
+	name := filepath.Join(dir, configName)
+	b, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		c := &projectConfig{opts: defaultOptions}
+		if !isRoot {
+			if c, err = loadConfig(parent); err != nil {
+				return nil, err
+			}
+		}
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var f configFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	c := &projectConfig{opts: defaultOptions}
+	if !f.Root && !isRoot {
+		if c, err = loadConfig(parent); err != nil {
+			return nil, err
+		}
+	}
+	c, err = c.with(dir, &f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return c, nil
+}
+
+// with returns a copy of c overridden by the settings in f,
+// which was found in dir.
+func (c *projectConfig) with(dir string, f *configFile) (*projectConfig, error) {
+	nc := *c
+	for _, o := range []struct {
+		set *bool
+		opt naive.Options
+	}{
+		{f.TrailingComma, naive.TrailingComma},
+		{f.AlignColumns, naive.AlignColumns},
+		{f.LowercaseKeywords, naive.LowercaseKeywords},
+		{f.Simplify, naive.Simplify},
+		{f.CollapseLists, naive.CollapseLists},
+	} {
+		switch {
+		case o.set == nil:
+		case *o.set:
+			nc.opts |= o.opt
+		default:
+			nc.opts &^= o.opt
+		}
+	}
+	if f.MaxWidth != nil {
+		if *f.MaxWidth < 0 {
+			return nil, fmt.Errorf("invalid maxWidth %d", *f.MaxWidth)
+		}
+		nc.maxWidth = *f.MaxWidth
+	}
+	if f.PHP != "" {
+		ver, err := phpver.Parse(f.PHP)
+		if err != nil {
+			return nil, err
+		}
+		nc.php = phpVersion{ver, "php in " + filepath.Join(dir, configName)}
+	}
+	if len(f.Exclude) > 0 {
+		l := ignore.Parse(filepath.ToSlash(dir), nil)
+		for _, pat := range f.Exclude {
+			l.Add(pat)
+		}
+		nc.exclude = append(slices.Clip(c.exclude), l)
+	}
+	return &nc, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindConfigError(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, configName)
+	if err := os.WriteFile(name, []byte(`{"maxWidth": -1}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	_, err1 := findConfig(dir)
+	if err1 == nil {
+		t.Fatal("got no error")
+	}
+	want := name + ": invalid maxWidth -1"
+	if err1.Error() != want {
+		t.Errorf("got %v, want %s", err1, want)
+	}
+
+	// The error is cached, also for subdirectories.
+	if err := os.WriteFile(name, []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err2 := findConfig(sub); err2 != err1 {
+		t.Errorf("got %v, want the cached error", err2)
+	}
+	forgetConfigs(dir)
+	if _, err := findConfig(sub); err != nil {
+		t.Errorf("unexpected error after forgetConfigs: %v", err)
+	}
+}
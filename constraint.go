@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// minConstraintVersion returns the lowest version satisfying
+// the Composer version constraint c, such as "^7.4 || ^8.0".
+// Versions are encoded the same way as PHP_VERSION_ID, e.g. 8.1.2
+// is 80102. If c does not restrict the lowest version, the result
+// is 0.
+//
+// See https://getcomposer.org/doc/articles/versions.md.
+func minConstraintVersion(c string) (int, error) {
+	c = strings.ReplaceAll(c, "||", "|")
+	minVer := math.MaxInt
+	for group := range strings.SplitSeq(c, "|") {
+		r, err := parseConstraintGroup(group)
+		if err != nil {
+			return 0, fmt.Errorf("invalid version constraint %q: %v", c, err)
+		}
+		if r.lo < r.hi {
+			minVer = min(minVer, r.lo)
+		}
+	}
+	if minVer == math.MaxInt {
+		return 0, fmt.Errorf("version constraint %q matches no version", c)
+	}
+	return minVer, nil
+}
+
+// versionRange represents versions v such that lo <= v < hi.
+type versionRange struct {
+	lo, hi int
+}
+
+var anyVersion = versionRange{lo: 0, hi: math.MaxInt}
+
+// parseConstraintGroup parses constraints that must all be satisfied,
+// i.e. ones separated by spaces or commas.
+func parseConstraintGroup(group string) (versionRange, error) {
+	fields := strings.FieldsFunc(group, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ','
+	})
+	if len(fields) == 0 {
+		return versionRange{}, fmt.Errorf("empty constraint")
+	}
+
+	r := anyVersion
+	for i := 0; i < len(fields); i++ {
+		var cr versionRange
+		var err error
+		switch f := fields[i]; {
+		case i+2 < len(fields) && fields[i+1] == "-":
+			// Hyphenated range, e.g. 7.4 - 8.2.
+			cr, err = parseHyphenRange(f, fields[i+2])
+			i += 2
+		case strings.Trim(f, "<>=!~^") == "" && i+1 < len(fields):
+			// An operator followed by a space, e.g. >= 7.4.
+			cr, err = parseConstraint(f + fields[i+1])
+			i++
+		default:
+			cr, err = parseConstraint(f)
+		}
+		if err != nil {
+			return versionRange{}, err
+		}
+		r.lo = max(r.lo, cr.lo)
+		r.hi = min(r.hi, cr.hi)
+	}
+	return r, nil
+}
+
+func parseHyphenRange(from, to string) (versionRange, error) {
+	lo, _, err := parseConstraintVersion(from)
+	if err != nil {
+		return versionRange{}, err
+	}
+	hi, wildcard, err := parseConstraintVersion(to)
+	if err != nil {
+		return versionRange{}, err
+	}
+	r := versionRange{lo: versionID(lo)}
+	if len(hi) == 0 {
+		r.hi = math.MaxInt
+	} else if len(hi) < 3 || wildcard {
+		// A partial upper bound is treated as a wildcard.
+		r.hi = versionID(bump(hi))
+	} else {
+		r.hi = versionID(hi) + 1
+	}
+	return r, nil
+}
+
+// parseConstraint parses a single constraint, e.g. ^8.1 or <8.3.
+func parseConstraint(c string) (versionRange, error) {
+	// Drop stability flags, e.g. ^8.0@dev.
+	c, _, _ = strings.Cut(c, "@")
+	if c == "*" || strings.HasPrefix(c, "dev-") {
+		return anyVersion, nil
+	}
+
+	i := strings.IndexFunc(c, func(r rune) bool {
+		return !strings.ContainsRune("<>=!~^", r)
+	})
+	if i < 0 {
+		return versionRange{}, fmt.Errorf("missing version in %q", c)
+	}
+	op, v := c[:i], c[i:]
+	parts, wildcard, err := parseConstraintVersion(v)
+	if err != nil {
+		return versionRange{}, err
+	}
+	if wildcard && op != "" && op != "==" && op != "=" {
+		return versionRange{}, fmt.Errorf("unexpected wildcard in %q", c)
+	}
+
+	id := versionID(parts)
+	r := anyVersion
+	switch op {
+	default:
+		return versionRange{}, fmt.Errorf("unknown operator %q", op)
+	case "", "=", "==":
+		if wildcard {
+			if len(parts) == 0 {
+				return anyVersion, nil
+			}
+			r = versionRange{lo: id, hi: versionID(bump(parts))}
+		} else {
+			r = versionRange{lo: id, hi: id + 1}
+		}
+	case ">=":
+		r.lo = id
+	case ">":
+		r.lo = id + 1
+	case "<=":
+		r.hi = id + 1
+	case "<":
+		r.hi = id
+	case "!=", "<>":
+		// Excluding a single version doesn't
+		// move the bounds in practice.
+	case "~":
+		r.lo = id
+		if len(parts) == 1 {
+			r.hi = versionID(bump(parts))
+		} else {
+			r.hi = versionID(bump(parts[:len(parts)-1]))
+		}
+	case "^":
+		r.lo = id
+		n := 1
+		for n < len(parts) && parts[n-1] == 0 {
+			n++
+		}
+		r.hi = versionID(bump(parts[:n]))
+	}
+	return r, nil
+}
+
+// parseConstraintVersion parses a version such as 8.1, v7.4.3,
+// 8.0.0-RC1, or 8.2.*. It reports whether the version ends with
+// a wildcard, which is not included in the returned parts.
+func parseConstraintVersion(v string) (parts []int, wildcard bool, err error) {
+	orig := v
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		// Drop a stability suffix or build metadata.
+		v = v[:i]
+	}
+	for p := range strings.SplitSeq(v, ".") {
+		if wildcard {
+			return nil, false, fmt.Errorf("invalid version %q", orig)
+		}
+		switch p {
+		case "*", "x", "X":
+			wildcard = true
+			continue
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, false, fmt.Errorf("invalid version %q", orig)
+		}
+		parts = append(parts, n)
+	}
+	if len(parts) > 4 {
+		return nil, false, fmt.Errorf("invalid version %q", orig)
+	}
+	return parts, wildcard, nil
+}
+
+// bump returns a copy of parts with the last part incremented.
+func bump(parts []int) []int {
+	b := append([]int(nil), parts...)
+	b[len(b)-1]++
+	return b
+}
+
+// versionID encodes parts like PHP_VERSION_ID. Missing parts are
+// zero; parts beyond the patch version are ignored.
+func versionID(parts []int) int {
+	id := 0
+	for i, mul := range []int{10000, 100, 1} {
+		if i < len(parts) {
+			id += min(parts[i], 99) * mul
+		}
+	}
+	return id
+}
@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestMinConstraintVersion(t *testing.T) {
+	tests := []struct {
+		constraint string
+		want       int
+	}{
+		{">=7.4", 70400},
+		{">= 7.4", 70400},
+		{">=8", 80000},
+		{">7.4", 70401},
+		{"^8.1", 80100},
+		{"^7.4 || ^8.0", 70400},
+		{"^8.0 | ^7.3", 70300},
+		{"~8.1.0", 80100},
+		{"~7", 70000},
+		{"8.2.*", 80200},
+		{"8.*", 80000},
+		{"8.1", 80100},
+		{"v8.1.2", 80102},
+		{"=8.1.2", 80102},
+		{">=7.4 <8.3", 70400},
+		{">=7.4,<8.3", 70400},
+		{">=7.2 <7.4 || >=8.1", 70200},
+		{"<7.0 >=7.4 || ^8.2", 80200},
+		{"7.4 - 8.2", 70400},
+		{"7.4.0 - 8.2.0", 70400},
+		{"^8.0@dev", 80000},
+		{">=8.1-dev", 80100},
+		{"8.0.0-RC1", 80000},
+		{"^0.3", 300},
+		{"*", 0},
+		{"<8.0", 0},
+		{"!=7.4.1", 0},
+		{"dev-main", 0},
+	}
+	for _, tt := range tests {
+		got, err := minConstraintVersion(tt.constraint)
+		if err != nil {
+			t.Errorf("minConstraintVersion(%q): unexpected err: %v", tt.constraint, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("minConstraintVersion(%q) = %d, want %d", tt.constraint, got, tt.want)
+		}
+	}
+}
+
+func TestMinConstraintVersionErrors(t *testing.T) {
+	for _, c := range []string{
+		"",
+		"^7.4 ||",
+		">=foo",
+		">=8.*",
+		"8.*.1",
+		">=8.1 <8.0",
+		"=>7.4",
+	} {
+		if v, err := minConstraintVersion(c); err == nil {
+			t.Errorf("minConstraintVersion(%q) = %d, want error", c, v)
+		}
+	}
+}
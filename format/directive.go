@@ -0,0 +1,59 @@
+package format
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"mibk.dev/phpfmt/naive"
+	"mibk.dev/phpfmt/token"
+)
+
+// A span is a range of code from start up to end, exclusive.
+type span struct {
+	start, end token.Pos
+}
+
+// verbatimSpans returns the spans of the code of file left
+// unformatted by directives (see [naive.Directive]), in order.
+func verbatimSpans(file *naive.File) []span {
+	var spans []span
+	naive.Inspect(file, func(n naive.Node) bool {
+		s, ok := n.(*naive.Stmt)
+		if !ok {
+			return true
+		}
+		for _, x := range s.Nodes() {
+			if tok, ok := x.(token.Token); ok && tok.Type == naive.VerbatimToken {
+				spans = append(spans, span{tok.Pos, endPos(tok)})
+			}
+		}
+		return true
+	})
+	return spans
+}
+
+// endPos returns the position just after tok.
+func endPos(tok token.Token) token.Pos {
+	p := tok.Pos
+	i := strings.LastIndexByte(tok.Text, '\n')
+	if i >= 0 {
+		p.Line += strings.Count(tok.Text, "\n")
+		p.Column = 1
+	}
+	p.Column += utf8.RuneCountInString(tok.Text[i+1:])
+	return p
+}
+
+// inSpans reports whether p is in any of spans.
+func inSpans(spans []span, p token.Pos) bool {
+	for _, s := range spans {
+		if !before(p, s.start) && before(p, s.end) {
+			return true
+		}
+	}
+	return false
+}
+
+func before(p, q token.Pos) bool {
+	return p.Line < q.Line || p.Line == q.Line && p.Column < q.Column
+}
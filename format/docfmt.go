@@ -8,11 +8,15 @@ import (
 	"strings"
 
 	"mibk.dev/phpfmt/phpdoc"
+	"mibk.dev/phpfmt/phpver"
 	"mibk.dev/phpfmt/token"
 )
 
-func formatDocs(filename string, src []byte) ([]byte, error) {
-	scan := token.NewScanner(bytes.NewReader(src), true)
+// formatDocs formats the doc comments in src, except for those
+// in the verbatim spans. Doc comments that cannot be parsed are
+// left as they are (see [docWarnings]).
+func formatDocs(filename string, src []byte, verbatim []span, ver phpver.Version) ([]byte, error) {
+	scan := token.NewScanner(bytes.NewReader(src), ver)
 	var out bytes.Buffer
 	w := &stickyErrWriter{w: &out}
 	var ws string
@@ -47,18 +51,11 @@ Loop:
 				}
 			}
 		}
-		if tok.Type == token.DocComment {
+		if tok.Type == token.DocComment && !inSpans(verbatim, tok.Pos) {
 			var err error
-			doc, err = phpdoc.Parse(strings.NewReader(tok.Text))
-			if err == nil {
+			if doc, err = phpdoc.Parse(strings.NewReader(tok.Text)); err == nil {
 				continue
 			}
-			pos := _Pos{Line: tok.Pos.Line, Column: tok.Pos.Column}
-			if se, ok := err.(*phpdoc.SyntaxError); ok {
-				pos = pos.Add(_Pos{Line: se.Line, Column: se.Column})
-				err = se.Err
-			}
-			return nil, fmt.Errorf("%s:%v: %v", filename, pos, err)
 		}
 		if ws != "" {
 			io.WriteString(w, ws)
@@ -84,13 +81,42 @@ Loop:
 	if err := scan.Err(); err != nil {
 		var scanErr *token.ScanError
 		if errors.As(err, &scanErr) {
-			return nil, fmt.Errorf("%s:%v: %v", filename, scanErr.Pos, scanErr.Err)
+			return nil, &Error{Filename: filename, Line: scanErr.Pos.Line, Column: scanErr.Pos.Column, Err: scanErr.Err}
 		}
 		return nil, fmt.Errorf("formatting %q: %v", filename, err)
 	}
 	return out.Bytes(), w.err
 }
 
+// docWarnings reports the doc comments in src that cannot be parsed,
+// and so are left unformatted by formatDocs. They are looked for in
+// the source code, rather than in the formatted code, so that their
+// positions are those in src. The verbatim spans are skipped.
+func docWarnings(filename string, src []byte, verbatim []span, ver phpver.Version) Warnings {
+	scan := token.NewScanner(bytes.NewReader(src), ver)
+	var warns Warnings
+	for {
+		tok := scan.Next()
+		if tok.Type == token.EOF {
+			// Scanning errors are reported by the parser.
+			return warns
+		}
+		if tok.Type != token.DocComment || inSpans(verbatim, tok.Pos) {
+			continue
+		}
+		_, err := phpdoc.Parse(strings.NewReader(tok.Text))
+		if err == nil {
+			continue
+		}
+		pos := _Pos{Line: tok.Pos.Line, Column: tok.Pos.Column}
+		if se, ok := err.(*phpdoc.SyntaxError); ok {
+			pos = pos.Add(_Pos{Line: se.Line, Column: se.Column})
+			err = se.Err
+		}
+		warns = append(warns, &Error{Filename: filename, Line: pos.Line, Column: pos.Column, Err: err})
+	}
+}
+
 type stickyErrWriter struct {
 	w   io.Writer
 	err error
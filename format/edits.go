@@ -0,0 +1,136 @@
+package format
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"mibk.dev/phpfmt/internal/diff"
+)
+
+// An Edit replaces the text between Start and End
+// in the source code with NewText.
+type Edit struct {
+	Start   Position `json:"start"`
+	End     Position `json:"end"`
+	NewText string   `json:"newText"`
+}
+
+// A Position is a position in the source code.
+// Line and the columns are 1-based.
+type Position struct {
+	Offset      int `json:"offset"`      // byte offset
+	Line        int `json:"line"`        // line number
+	Column      int `json:"column"`      // column in bytes
+	UTF16Column int `json:"utf16Column"` // column in UTF-16 code units, as in LSP
+}
+
+// Edits returns the edits that turn src into res, e.g. the source code
+// and its formatted version. The edits are sorted, do not overlap, and
+// are as small as possible, so that they can be applied without
+// disturbing the rest of the code (cursor positions, review comments).
+// Their positions refer to src.
+func Edits(src, res []byte) []Edit {
+	a := diff.SplitLines(src)
+	b := diff.SplitLines(res)
+
+	// Offsets of the lines in src and res.
+	aoff, boff := lineOffsets(a), lineOffsets(b)
+
+	var edits []Edit
+	for _, c := range lineChanges(a, b) {
+		// Refine the changed lines to words.
+		start, end := aoff[c.A0], aoff[c.A1]
+		x, y := splitWords(string(src[start:end])), splitWords(string(res[boff[c.B0]:boff[c.B1]]))
+		xoff := lineOffsets(x)
+		for _, w := range diff.Lines(x, y) {
+			e := Edit{NewText: strings.Join(y[w.B0:w.B1], "")}
+			s, t := start+xoff[w.A0], start+xoff[w.A1]
+			if n := len(edits); n > 0 && edits[n-1].End.Offset == s {
+				// Merge adjacent edits.
+				edits[n-1].End.Offset = t
+				edits[n-1].NewText += e.NewText
+				continue
+			}
+			e.Start.Offset, e.End.Offset = s, t
+			edits = append(edits, e)
+		}
+	}
+
+	// Compute the lines and columns.
+	line := 0
+	pos := func(off int) Position {
+		for line+1 < len(a) && aoff[line+1] <= off {
+			line++
+		}
+		if line+1 == len(a) && off == aoff[len(a)] && strings.HasSuffix(a[line], "\n") {
+			// The very end of src after a final newline.
+			return Position{Offset: off, Line: len(a) + 1, Column: 1, UTF16Column: 1}
+		}
+		prefix := string(src[aoff[line]:off])
+		return Position{
+			Offset:      off,
+			Line:        line + 1,
+			Column:      len(prefix) + 1,
+			UTF16Column: UTF16Len(prefix) + 1,
+		}
+	}
+	for i := range edits {
+		edits[i].Start = pos(edits[i].Start.Offset)
+		edits[i].End = pos(edits[i].End.Offset)
+	}
+	return edits
+}
+
+// lineOffsets returns the offsets of lines, and the total length
+// as the last element.
+func lineOffsets(lines []string) []int {
+	off := make([]int, len(lines)+1)
+	for i, l := range lines {
+		off[i+1] = off[i] + len(l)
+	}
+	return off
+}
+
+// splitWords splits s into words, runs of spaces and tabs,
+// and single other characters.
+func splitWords(s string) []string {
+	var words []string
+	for s != "" {
+		r, n := utf8.DecodeRuneInString(s)
+		class := charClass(r)
+		if class != 0 {
+			for n < len(s) {
+				r, size := utf8.DecodeRuneInString(s[n:])
+				if charClass(r) != class {
+					break
+				}
+				n += size
+			}
+		}
+		words = append(words, s[:n])
+		s = s[n:]
+	}
+	return words
+}
+
+func charClass(r rune) int {
+	switch {
+	case r == ' ' || r == '\t':
+		return 1
+	case r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 2
+	}
+	return 0
+}
+
+// UTF16Len returns the length of s in UTF-16 code units,
+// in which, e.g., LSP counts columns.
+func UTF16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
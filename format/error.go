@@ -0,0 +1,41 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotEquivalent is wrapped by the errors reporting that the formatted
+// code is not equivalent to the source code (see [Config.Verify]).
+var ErrNotEquivalent = errors.New("formatted code differs")
+
+// An Error is a problem found in PHP source code,
+// together with its position.
+type Error struct {
+	Filename     string
+	Line, Column int
+	Err          error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %v", e.Filename, e.Line, e.Column, e.Err)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Warnings is a list of problems that did not prevent
+// the code from being formatted. It is returned by [Source]
+// along with the formatted code.
+type Warnings []*Error
+
+func (w Warnings) Error() string {
+	var b strings.Builder
+	for i, e := range w {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(e.Error())
+	}
+	return b.String()
+}
@@ -2,56 +2,141 @@ package format
 
 import (
 	"bytes"
-	"fmt"
 	"io"
-	"log"
 	"slices"
 	"strings"
 
 	"mibk.dev/phpfmt/naive"
+	"mibk.dev/phpfmt/phpver"
 )
 
-// Pipe reads PHP source code from in, formats it, and writes the result to out.
-// The format can be slightly tweaked using opts. (See [naive.Options].)
-// The filename argument is used to set the “filename” in error messages.
-func Pipe(filename string, out io.Writer, in io.Reader, opts naive.Options) error {
-	src, err := io.ReadAll(in)
+// Config configures the formatting of PHP source code.
+type Config struct {
+	// Options tweak the format. (See [naive.Options].)
+	Options naive.Options
+
+	// PHP is the PHP version the code is written for.
+	// The zero value means the latest version.
+	PHP phpver.Version
+
+	// Filename is used as the file name in errors.
+	Filename string
+
+	// Lines restricts formatting to the statements that overlap
+	// any of the line ranges. The rest of the code is left intact,
+	// and use statements are not sorted.
+	// If Lines is empty, the whole code is formatted.
+	Lines []LineRange
+
+	// MaxWidth is the maximum width of lines (see [naive.Config]).
+	// Zero means no limit.
+	MaxWidth int
+
+	// Verify checks that the formatted code consists of the same
+	// tokens as the source code, except for the changes the formatter
+	// is allowed to make (see [Source]).
+	Verify bool
+}
+
+// Source formats the PHP source code src according to cfg.
+//
+// If src cannot be parsed, or if cfg.Verify is set and the formatted
+// code is not equivalent to src, the error is of type *Error.
+// The formatted code may differ from src in whitespace, comment
+// formatting, the case of keywords, string quoting, the order of use
+// statements, and trailing commas, but not in other tokens.
+// If src was formatted, but some parts of it were left as they were
+// (e.g. doc comments that could not be parsed), the result is
+// returned together with an error of type Warnings.
+func Source(src []byte, cfg Config) ([]byte, error) {
+	file, err := parse(cfg.Filename, src, cfg.PHP)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	var b bytes.Buffer
-	if err := formatCode(filename, &b, bytes.NewReader(src), opts); err != nil {
-		return err
+	pcfg := &naive.Config{Options: cfg.Options, PHP: cfg.PHP, MaxWidth: cfg.MaxWidth}
+	if err := pcfg.Fprint(&b, file); err != nil {
+		return nil, err
 	}
 
-	code := orderUseStmts(b.Bytes())
+	code := b.Bytes()
 
-	if opts&naive.AlignColumns > 0 {
-		withdoc, err := formatDocs(filename, code)
+	// The code left unformatted by directives must not be changed
+	// by the passes below, which work on the formatted code.
+	srcSpans := verbatimSpans(file)
+	var spans []span
+	if len(srcSpans) > 0 {
+		out, err := parse(cfg.Filename, code, cfg.PHP)
 		if err != nil {
-			log.Println("WARN:", err)
-		} else {
-			code = withdoc
+			return nil, err
 		}
+		spans = verbatimSpans(out)
+	}
+
+	if len(cfg.Lines) == 0 {
+		// Sorting moves lines, so it cannot be limited to some.
+		code = orderUseStmts(code, spans)
 	}
 
-	_, err = out.Write(code)
+	var warns Warnings
+	if cfg.Options&naive.AlignColumns > 0 {
+		code, err = formatDocs(cfg.Filename, code, spans, cfg.PHP)
+		if err != nil {
+			return nil, err
+		}
+		warns = docWarnings(cfg.Filename, src, srcSpans, cfg.PHP)
+	}
+	if len(cfg.Lines) > 0 {
+		code = selectLines(file, src, code, cfg.Lines)
+	}
+	if cfg.Verify {
+		if err := verify(cfg.Filename, src, code, cfg.PHP); err != nil {
+			return nil, err
+		}
+	}
+	if len(warns) > 0 {
+		return code, warns
+	}
+	return code, nil
+}
+
+// Pipe reads PHP source code from in, formats it, and writes the result to out.
+// The format can be slightly tweaked using opts. (See [naive.Options].)
+// The code is formatted for the PHP version ver; the zero version
+// means the latest one.
+// The filename argument is used to set the “filename” in error messages.
+//
+// As with [Source], the result is written to out even if the returned
+// error is of type Warnings.
+func Pipe(filename string, out io.Writer, in io.Reader, opts naive.Options, ver phpver.Version) error {
+	src, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	code, err := Source(src, Config{Options: opts, PHP: ver, Filename: filename})
+	if _, ok := err.(Warnings); err != nil && !ok {
+		return err
+	}
+	if _, werr := out.Write(code); werr != nil {
+		return werr
+	}
 	return err
 }
 
-func formatCode(filename string, out io.Writer, in io.Reader, opts naive.Options) error {
-	file, err := naive.Parse(in, opts&naive.PHP74Compat > 0)
+func parse(filename string, src []byte, ver phpver.Version) (*naive.File, error) {
+	file, err := naive.Parse(bytes.NewReader(src), ver)
 	if se, ok := err.(*naive.SyntaxError); ok {
-		return fmt.Errorf("%s:%d:%d: %v", filename, se.Line, se.Column, se.Err)
-	} else if err != nil {
-		return err
+		return nil, &Error{Filename: filename, Line: se.Line, Column: se.Column, Err: se.Err}
 	}
-	return naive.Fprint(out, file, opts)
+	return file, err
 }
 
 var slashes = strings.NewReplacer("\\", ";")
 
-func orderUseStmts(src []byte) []byte {
+// orderUseStmts sorts the runs of use statements in src,
+// which is formatted code, so there is one on each line.
+// The lines of the verbatim spans are kept.
+func orderUseStmts(src []byte, verbatim []span) []byte {
 	var b bytes.Buffer
 	var stmts []string
 
@@ -67,8 +152,21 @@ func orderUseStmts(src []byte) []byte {
 
 	const namespace = "namespace "
 	const use = "use "
+	keep := make(map[int]bool)
+	for _, s := range verbatim {
+		for l := s.start.Line; l <= s.end.Line; l++ {
+			keep[l] = true
+		}
+	}
+	n := 0
 	for line := range bytes.Lines(src) {
 		line := string(line)
+		n++
+		if keep[n] {
+			flush()
+			b.WriteString(line)
+			continue
+		}
 		if name, ok := strings.CutPrefix(line, namespace); ok {
 			line = namespace + strings.TrimLeft(name, "\\")
 		} else if strings.HasPrefix(line, use) && !strings.Contains(line, "{") {
@@ -0,0 +1,137 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"mibk.dev/phpfmt/internal/diff"
+	"mibk.dev/phpfmt/naive"
+)
+
+// A LineRange is a range of lines from Start to End (1-based, inclusive).
+type LineRange struct {
+	Start, End int
+}
+
+// ParseLineRange parses a line range in the form "start:end".
+func ParseLineRange(s string) (LineRange, error) {
+	a, b, ok := strings.Cut(s, ":")
+	if !ok {
+		return LineRange{}, fmt.Errorf("invalid line range %q: expecting start:end", s)
+	}
+	var r LineRange
+	if _, err := fmt.Sscanf(a+" "+b, "%d %d", &r.Start, &r.End); err != nil {
+		return LineRange{}, fmt.Errorf("invalid line range %q", s)
+	}
+	if r.Start < 1 || r.End < r.Start {
+		return LineRange{}, fmt.Errorf("invalid line range %q", s)
+	}
+	return r, nil
+}
+
+func (r LineRange) String() string { return fmt.Sprintf("%d:%d", r.Start, r.End) }
+
+// selectLines returns src with only those changes from src to res
+// applied that overlap ranges. The ranges are first expanded to whole
+// statements (see [naive.File.ExpandLines]), and then to whole changes,
+// so that a statement is never formatted only partially.
+func selectLines(file *naive.File, src, res []byte, ranges []LineRange) []byte {
+	a := diff.SplitLines(src)
+	b := diff.SplitLines(res)
+	changes := lineChanges(a, b)
+
+	// Regions are 0-based, half-open line ranges in src.
+	type region struct{ lo, hi int }
+	regions := make([]region, len(ranges))
+	for i, r := range ranges {
+		lo, hi := file.ExpandLines(r.Start, r.End)
+		regions[i] = region{lo - 1, hi}
+	}
+	overlaps := func(c diff.Change, r region) bool {
+		if c.A0 == c.A1 {
+			// Insertions at the region edges belong to the neighbours.
+			return r.lo < c.A0 && c.A0 < r.hi
+		}
+		return c.A0 < r.hi && r.lo < c.A1
+	}
+
+	selected := make([]bool, len(changes))
+	for i := range regions {
+		r := &regions[i]
+		for {
+			old := *r
+			for j, c := range changes {
+				if overlaps(c, *r) {
+					selected[j] = true
+					r.lo, r.hi = min(r.lo, c.A0), max(r.hi, c.A1)
+				}
+			}
+			lo, hi := file.ExpandLines(r.lo+1, r.hi)
+			r.lo, r.hi = lo-1, hi
+			if *r == old {
+				break
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	i := 0
+	for j, c := range changes {
+		for ; i < c.A1; i++ {
+			if i >= c.A0 && selected[j] {
+				continue
+			}
+			out.WriteString(a[i])
+		}
+		if selected[j] {
+			for _, line := range b[c.B0:c.B1] {
+				out.WriteString(line)
+			}
+		}
+	}
+	for ; i < len(a); i++ {
+		out.WriteString(a[i])
+	}
+	return out.Bytes()
+}
+
+// lineChanges returns the changes needed to turn a into b, as small
+// as possible: lines that differ only in spaces and tabs are paired,
+// and so are the lines of changes that keep the number of lines
+// (most likely, they were changed one by one).
+func lineChanges(a, b []string) []diff.Change {
+	squash := func(lines []string) []string {
+		s := make([]string, len(lines))
+		for i, l := range lines {
+			s[i] = strings.Map(func(r rune) rune {
+				if r == ' ' || r == '\t' {
+					return -1
+				}
+				return r
+			}, l)
+		}
+		return s
+	}
+
+	var changes []diff.Change
+	pair := func(a0, a1, b0 int) {
+		for i := range a1 - a0 {
+			if a[a0+i] != b[b0+i] {
+				changes = append(changes, diff.Change{A0: a0 + i, A1: a0 + i + 1, B0: b0 + i, B1: b0 + i + 1})
+			}
+		}
+	}
+	i, j := 0, 0
+	for _, c := range diff.Lines(squash(a), squash(b)) {
+		pair(i, c.A0, j)
+		if c.A1-c.A0 == c.B1-c.B0 {
+			pair(c.A0, c.A1, c.B0)
+		} else {
+			changes = append(changes, c)
+		}
+		i, j = c.A1, c.B1
+	}
+	pair(i, len(a), j)
+	return changes
+}
@@ -0,0 +1,28 @@
+package format
+
+import (
+	"testing"
+
+	"mibk.dev/phpfmt/naive"
+)
+
+func TestSourceLinesUseStmts(t *testing.T) {
+	const src = "<?php\nuse B\\X;\nuse A\\Y;\nuse  C\\Z;\n"
+	tests := []struct {
+		lines LineRange
+		want  string
+	}{
+		{LineRange{3, 3}, src},
+		{LineRange{4, 4}, "<?php\nuse B\\X;\nuse A\\Y;\nuse C\\Z;\n"},
+	}
+	for _, tt := range tests {
+		cfg := Config{Options: naive.Standard, Lines: []LineRange{tt.lines}, Verify: true}
+		got, err := Source([]byte(src), cfg)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.lines, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%v: got\n%s\nwant\n%s", tt.lines, got, tt.want)
+		}
+	}
+}
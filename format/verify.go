@@ -0,0 +1,235 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"mibk.dev/phpfmt/phpver"
+	"mibk.dev/phpfmt/token"
+)
+
+// verify checks that the formatted code res is equivalent to the
+// source code src: both must consist of the same significant tokens,
+// except for the rewrites the formatter is allowed to make. These are:
+//
+//   - changes of whitespace, including line endings,
+//   - changes of whitespace and asterisks in comments, and dropping
+//     empty comments,
+//   - changes of the case of keywords,
+//   - rewriting double-quoted strings to single-quoted ones,
+//   - sorting use statements, and dropping their leading backslashes,
+//   - adding and removing trailing commas.
+//
+// If the tokens differ, the error is of type *Error and reports the
+// position of the first difference in src.
+func verify(filename string, src, res []byte, ver phpver.Version) error {
+	want, wantComments, wantEOF, err := significantTokens(src, ver)
+	if err != nil {
+		return err
+	}
+	got, gotComments, gotEOF, err := significantTokens(res, ver)
+	if err != nil {
+		return &Error{Filename: filename, Line: 1, Column: 1, Err: fmt.Errorf("formatted code cannot be scanned: %v", err)}
+	}
+	// Comments are compared separately, because
+	// they may be moved around commas and the like.
+	if err := compareTokens(filename, want, got, wantEOF, gotEOF); err != nil {
+		return err
+	}
+	return compareTokens(filename, wantComments, gotComments, wantEOF, gotEOF)
+}
+
+// compareTokens compares the tokens of the source code, want,
+// with the tokens of the formatted code, got. The positions
+// of EOF in them are wantEOF and gotEOF.
+func compareTokens(filename string, want, got []sigToken, wantEOF, gotEOF token.Pos) error {
+	for i := range max(len(want), len(got)) {
+		w := sigToken{Token: token.Token{Type: token.EOF, Pos: wantEOF}}
+		g := sigToken{Token: token.Token{Type: token.EOF, Pos: gotEOF}}
+		if i < len(want) {
+			w = want[i]
+		}
+		if i < len(got) {
+			g = got[i]
+		}
+		if w.Type == g.Type && w.norm == g.norm {
+			continue
+		}
+		pos := w.Pos
+		if i >= len(want) && len(want) > 0 {
+			// Report the extra tokens after the last token.
+			pos = want[len(want)-1].Pos
+		}
+		return &Error{
+			Filename: filename,
+			Line:     pos.Line,
+			Column:   pos.Column,
+			Err: fmt.Errorf("%w: found %s at %d:%d, expecting %s", ErrNotEquivalent,
+				describe(g), g.Pos.Line, g.Pos.Column, describe(w)),
+		}
+	}
+	return nil
+}
+
+func describe(tok sigToken) string {
+	if tok.Type == token.EOF {
+		return "EOF"
+	}
+	return fmt.Sprintf("%q", tok.Text)
+}
+
+// A sigToken is a significant token, i.e. not whitespace.
+type sigToken struct {
+	token.Token
+	norm string // normalized text
+}
+
+// significantTokens returns the tokens of src that are not whitespace,
+// normalized so that the allowed rewrites are not considered differences.
+// Comments are returned separately, and so is the position of EOF.
+func significantTokens(src []byte, ver phpver.Version) (code, comments []sigToken, eof token.Pos, err error) {
+	scan := token.NewScanner(bytes.NewReader(src), ver)
+	for {
+		tok := sigToken{Token: scan.Next()}
+		if tok.Type == token.EOF {
+			eof = tok.Pos
+			break
+		}
+		tok.norm = strings.ReplaceAll(tok.Text, "\r", "")
+		switch {
+		case tok.Type == token.Whitespace:
+			continue
+		case tok.Type == token.Comment || tok.Type == token.DocComment:
+			tok.Type = token.Comment
+			tok.norm = strings.Map(func(r rune) rune {
+				if r == '*' || r == '/' || r == '#' || strings.ContainsRune(" \t\n", r) {
+					return -1
+				}
+				return r
+			}, tok.norm)
+			if tok.norm != "" {
+				comments = append(comments, tok)
+			}
+			continue
+		case tok.Type == token.InlineHTML:
+			tok.norm = strings.Trim(tok.norm, " \t\n")
+			if tok.norm == "" {
+				continue
+			}
+		case tok.Type == token.OpenTag:
+			tok.norm = strings.ToLower(strings.TrimSpace(tok.norm))
+		case tok.Type == token.String:
+			if v, ok := stringValue(tok.norm); ok {
+				tok.norm = "'" + v
+			}
+		case tok.Type.IsReserved():
+			tok.norm = strings.ToLower(tok.norm)
+		case tok.Type == token.Backslash:
+			if n := len(code); n > 0 && (code[n-1].Type == token.Use || code[n-1].Type == token.Namespace) {
+				continue
+			}
+		}
+		code = append(code, tok)
+	}
+	if err := scan.Err(); err != nil {
+		return nil, nil, eof, err
+	}
+
+	// Drop trailing commas.
+	for i := len(code) - 2; i >= 0; i-- {
+		if code[i].Type != token.Comma {
+			continue
+		}
+		switch code[i+1].Type {
+		case token.Rparen, token.Rbrack, token.Rbrace:
+			code = slices.Delete(code, i, i+1)
+		}
+	}
+
+	sortUseStmts(code)
+	return code, comments, eof, nil
+}
+
+// sortUseStmts sorts the runs of consecutive use statements in toks.
+func sortUseStmts(toks []sigToken) {
+	var run [][]sigToken // the statements of the current run
+	start := 0           // where the current run starts
+	flush := func(end int) {
+		if len(run) > 1 {
+			sorted := slices.Clone(run)
+			slices.SortStableFunc(sorted, func(a, b []sigToken) int {
+				return strings.Compare(tokensText(a), tokensText(b))
+			})
+			// Copy the sorted statements over the run; only the
+			// order of the statements, not their length, changes.
+			var flat []sigToken
+			for _, stmt := range sorted {
+				flat = append(flat, stmt...)
+			}
+			copy(toks[start:end], flat)
+		}
+		run = nil
+	}
+	for i := 0; i < len(toks); {
+		if toks[i].Type != token.Use {
+			flush(i)
+			i++
+			start = i
+			continue
+		}
+		j := i
+		for j < len(toks) && toks[j].Type != token.Semicolon {
+			j++
+		}
+		if j < len(toks) {
+			j++ // include ;
+		}
+		run = append(run, slices.Clone(toks[i:j]))
+		i = j
+	}
+	flush(len(toks))
+}
+
+func tokensText(toks []sigToken) string {
+	var b strings.Builder
+	for _, tok := range toks {
+		b.WriteString(tok.norm)
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// stringValue returns the value of the single- or double-quoted
+// string literal s. For double-quoted strings, it reports false
+// unless the value is the same as the text between the quotes,
+// except for escaped backslashes and quotes.
+func stringValue(s string) (string, bool) {
+	if len(s) < 2 {
+		return "", false
+	}
+	quote, content := s[0], s[1:len(s)-1]
+	switch {
+	case quote == '`':
+		// A shell command.
+		return "", false
+	case quote == '"' && strings.Contains(content, "$"):
+		return "", false
+	}
+	var b strings.Builder
+	for i := 0; i < len(content); i++ {
+		ch := content[i]
+		if ch == '\\' && i+1 < len(content) {
+			switch next := content[i+1]; {
+			case next == '\\', next == quote:
+				ch = next
+				i++
+			case quote == '"':
+				return "", false
+			}
+		}
+		b.WriteByte(ch)
+	}
+	return b.String(), true
+}
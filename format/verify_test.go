@@ -0,0 +1,35 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		src, res string
+		err      string // empty if equivalent
+	}{
+		{"<?php\r\nfoo( 1,2 );", "<?php\n\nfoo(1, 2);\n", ""},
+		{"<?php\nECHO \"a\\\\b\\\"\";", "<?php\necho 'a\\\\b\"';\n", ""},
+		{"<?php\nuse \\B;\nuse A;\n", "<?php\n\nuse A;\nuse B;\n", ""},
+		{"<?php\nfoo(\n\t1 // one\n\t, 2\n);", "<?php\nfoo(\n\t1, // one\n\t2,\n);\n", ""},
+		{"<?php\n/**\n   @var int */\n$a;", "<?php\n/**\n * @var int\n */\n$a;\n", ""},
+		{"<?php\n$a = \"\\n\";", "<?php\n$a = '\\n';\n", `2:6: formatted code differs: found "'\\n'" at 2:6, expecting "\"\\n\""`},
+		{"<?php\n`ls`;", "<?php\n'ls';\n", `2:1: formatted code differs: found "'ls'" at 2:1, expecting "` + "`ls`" + `"`},
+		{"<?php\nfoo(1, 2);", "<?php\nfoo(1);\n", `2:6: formatted code differs: found ")" at 2:6, expecting ","`},
+		{"<?php\nfoo();", "<?php\nfoo();\nbar();\n", `2:6: formatted code differs: found "bar" at 3:1, expecting EOF`},
+		{"<?php\n// keep me\n", "<?php\n", `2:1: formatted code differs: found EOF at 2:1, expecting "// keep me"`},
+		{"<?php\n", "<?php\n// new\n", `2:1: formatted code differs: found "// new" at 2:1, expecting EOF`},
+		{"<?php\nfoo();", "<?php\nfoo();\n// new\n", `2:7: formatted code differs: found "// new" at 3:1, expecting EOF`},
+	}
+	for _, tt := range tests {
+		err := verify("x.php", []byte(tt.src), []byte(tt.res), 0)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("verify(%q, %q): unexpected error: %v", tt.src, tt.res, err)
+		case tt.err != "" && (err == nil || !strings.HasSuffix(err.Error(), tt.err)):
+			t.Errorf("verify(%q, %q): got error %v, want %s", tt.src, tt.res, err, tt.err)
+		}
+	}
+}
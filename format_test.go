@@ -2,16 +2,20 @@ package main_test
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
 
 	"mibk.dev/phpfmt/format"
 	"mibk.dev/phpfmt/naive"
+	"mibk.dev/phpfmt/phpver"
 	"rsc.io/diff"
 )
 
@@ -45,23 +49,28 @@ func testFmt(t *testing.T, filename string) {
 	t.Log(goldenName)
 
 	opts := naive.Standard
+	var ver phpver.Version
+	var maxWidth int
 	firstLine, _, _ := strings.Cut(string(input), "\n")
 	if _, cfg, ok := strings.Cut(firstLine, "// PHP"); ok {
-		cfg = strings.TrimSpace(cfg)
-		if v, err := strconv.Atoi(cfg); err == nil {
-			if v < 80000 {
-				opts |= naive.PHP74Compat
+		for _, cfg := range strings.Fields(cfg) {
+			if v, err := strconv.Atoi(cfg); err == nil {
+				ver = phpver.Version(v)
+			} else if w, ok := strings.CutPrefix(cfg, "maxwidth="); ok {
+				maxWidth, _ = strconv.Atoi(w)
+			} else if cfg == "-align" {
+				opts &= ^naive.AlignColumns
+			} else if cfg == "+simplify" {
+				opts |= naive.Simplify
+			} else if cfg == "+collapse" {
+				opts |= naive.CollapseLists
 			}
-		} else if cfg == "-align" {
-			opts &= ^naive.AlignColumns
-		} else if cfg == "+simplify" {
-			opts |= naive.Simplify
 		}
 	}
 
-	got := fmtInput(t, input, opts)
+	got := fmtInput(t, input, opts, ver, maxWidth)
 	// TODO: Do not require running fmtInput twice.
-	got = fmtInput(t, got, opts)
+	got = fmtInput(t, got, opts, ver, maxWidth)
 
 	if *rewriteGolden {
 		os.WriteFile(goldenName, got, 0o644)
@@ -74,11 +83,114 @@ func testFmt(t *testing.T, filename string) {
 	}
 }
 
-func fmtInput(t *testing.T, src []byte, opts naive.Options) []byte {
+func fmtInput(t *testing.T, src []byte, opts naive.Options, ver phpver.Version, maxWidth int) []byte {
 	t.Helper()
-	buf := new(bytes.Buffer)
-	if err := format.Pipe("<test>", buf, bytes.NewReader(src), opts); err != nil {
+	cfg := format.Config{Options: opts, PHP: ver, MaxWidth: maxWidth, Filename: "<test>", Verify: true}
+	res, err := format.Source(src, cfg)
+	if err != nil {
 		t.Errorf("unexpected err: %v", err)
 	}
-	return buf.Bytes()
+	return res
+}
+
+func TestSourceErrors(t *testing.T) {
+	cfg := format.Config{Options: naive.Standard, Filename: "x.php"}
+
+	_, err := format.Source([]byte("<?php\n\nfoo(1));\n"), cfg)
+	var fe *format.Error
+	if !errors.As(err, &fe) {
+		t.Fatalf("got %v (%T), want *format.Error", err, err)
+	}
+	if fe.Filename != "x.php" || fe.Line != 3 || fe.Column != 7 {
+		t.Errorf("got error at %s:%d:%d, want x.php:3:7", fe.Filename, fe.Line, fe.Column)
+	}
+
+	src := "<?php\n\n/**\n * @param array{ $x\n */\nfunction f($x)\n{\n}\n"
+	got, err := format.Source([]byte(src), cfg)
+	warns, ok := err.(format.Warnings)
+	if !ok || len(warns) != 1 {
+		t.Fatalf("got %v, want a single warning", err)
+	}
+	if w := warns[0]; w.Line != 4 || w.Column != 18 {
+		t.Errorf("got warning at %d:%d, want 4:18", w.Line, w.Column)
+	}
+	if string(got) != src {
+		t.Errorf("got\n%s\nwant the doc comment left untouched", got)
+	}
+
+	// The positions are those in the source code.
+	src = "<?php\n\n\n\n  /**\n   * @param array{ $x\n   */\nfunction f($x)\n{\n}\n"
+	_, err = format.Source([]byte(src), cfg)
+	warns, ok = err.(format.Warnings)
+	if !ok || len(warns) != 1 {
+		t.Fatalf("got %v, want a single warning", err)
+	}
+	if w := warns[0]; w.Line != 6 || w.Column != 20 {
+		t.Errorf("got warning at %d:%d, want 6:20", w.Line, w.Column)
+	}
+}
+
+func TestSourceLines(t *testing.T) {
+	src := "<?php\n\nfunction f()\n{\n\techo  1;\n\techo  2;\n\techo  3;\n}\n$a=1;\n"
+	tests := []struct {
+		lines []string
+		want  string
+	}{
+		{[]string{"6:6"}, "<?php\n\nfunction f()\n{\n\techo  1;\n\techo 2;\n\techo  3;\n}\n$a=1;\n"},
+		{[]string{"5:5", "9:9"}, "<?php\n\nfunction f()\n{\n\techo 1;\n\techo  2;\n\techo  3;\n}\n$a = 1;\n"},
+		{[]string{"3:3"}, "<?php\n\nfunction f()\n{\n\techo 1;\n\techo 2;\n\techo 3;\n}\n$a=1;\n"},
+	}
+	for _, tt := range tests {
+		cfg := format.Config{Options: naive.Standard}
+		for _, s := range tt.lines {
+			r, err := format.ParseLineRange(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg.Lines = append(cfg.Lines, r)
+		}
+		got, err := format.Source([]byte(src), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%v: got\n%s\nwant\n%s", tt.lines, got, tt.want)
+		}
+	}
+}
+
+func TestEdits(t *testing.T) {
+	tests := []struct {
+		src, res string
+		want     []format.Edit
+	}{
+		{"", "", nil},
+		{"$a=1;\n", "$a = 1;\n", []format.Edit{
+			{Start: format.Position{Offset: 2, Line: 1, Column: 3, UTF16Column: 3}, End: format.Position{Offset: 2, Line: 1, Column: 3, UTF16Column: 3}, NewText: " "},
+			{Start: format.Position{Offset: 3, Line: 1, Column: 4, UTF16Column: 4}, End: format.Position{Offset: 3, Line: 1, Column: 4, UTF16Column: 4}, NewText: " "},
+		}},
+		{"'😀ž',  1", "'😀ž', 1\n", []format.Edit{
+			{Start: format.Position{Offset: 9, Line: 1, Column: 10, UTF16Column: 7}, End: format.Position{Offset: 11, Line: 1, Column: 12, UTF16Column: 9}, NewText: " "},
+			{Start: format.Position{Offset: 12, Line: 1, Column: 13, UTF16Column: 10}, End: format.Position{Offset: 12, Line: 1, Column: 13, UTF16Column: 10}, NewText: "\n"},
+		}},
+		{"a\n\n\nb\n", "a\n\nb\nc\n", []format.Edit{
+			{Start: format.Position{Offset: 3, Line: 3, Column: 1, UTF16Column: 1}, End: format.Position{Offset: 4, Line: 4, Column: 1, UTF16Column: 1}, NewText: ""},
+			{Start: format.Position{Offset: 6, Line: 5, Column: 1, UTF16Column: 1}, End: format.Position{Offset: 6, Line: 5, Column: 1, UTF16Column: 1}, NewText: "c\n"},
+		}},
+	}
+	for _, tt := range tests {
+		edits := format.Edits([]byte(tt.src), []byte(tt.res))
+		if !reflect.DeepEqual(edits, tt.want) {
+			t.Errorf("Edits(%q, %q):\ngot  %+v\nwant %+v", tt.src, tt.res, edits, tt.want)
+		}
+
+		// Apply the edits backwards.
+		got := tt.src
+		for _, e := range slices.Backward(edits) {
+			got = got[:e.Start.Offset] + e.NewText + got[e.End.Offset:]
+		}
+		if got != tt.res {
+			t.Errorf("applying edits to %q: got %q, want %q", tt.src, got, tt.res)
+		}
+	}
 }
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"mibk.dev/phpfmt/format"
+	"mibk.dev/phpfmt/internal/ignore"
+)
+
+// A changedFile is a file changed relative to a Git revision,
+// together with the lines that were added or modified.
+type changedFile struct {
+	path  string
+	lines []format.LineRange
+}
+
+// gitChanges returns the PHP files under paths that differ between
+// the Git revision rev and the working tree, including untracked files
+// that are not ignored. Deleted files are left out. If there are no
+// paths, the current directory is used. The paths of the files are
+// relative to the current directory, and so is top, the top-level
+// directory of the working tree.
+func gitChanges(rev string, paths []string) (top string, files []changedFile, err error) {
+	out, err := git("rev-parse", "rev-parse", "--show-prefix")
+	if err != nil {
+		return "", nil, err
+	}
+	// The path of the current directory relative to the top-level one.
+	prefix := filepath.FromSlash(strings.TrimSpace(string(out)))
+	if top, err = filepath.Rel(prefix, "."); err != nil {
+		return "", nil, err
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	// The prefixes are set explicitly, as they can be changed,
+	// e.g., by diff.noprefix or diff.mnemonicPrefix.
+	// The names are relative to the top-level directory.
+	args := []string{
+		"-c", "core.quotePath=false",
+		"diff", "--no-color", "--no-ext-diff",
+		"--src-prefix=a/", "--dst-prefix=b/",
+		"-U0", "--diff-filter=ACMR", rev, "--",
+	}
+	out, err = git("diff "+rev, append(args, paths...)...)
+	if err != nil {
+		return "", nil, err
+	}
+	files, err = parseGitDiff(out)
+	if err != nil {
+		return "", nil, err
+	}
+	for i := range files {
+		if files[i].path, err = filepath.Rel(prefix, files[i].path); err != nil {
+			return "", nil, err
+		}
+	}
+
+	// The names are relative to the current directory.
+	args = []string{"ls-files", "-z", "--others", "--exclude-standard", "--"}
+	out, err = git("ls-files", append(args, paths...)...)
+	if err != nil {
+		return "", nil, err
+	}
+	for name := range strings.SplitSeq(string(out), "\x00") {
+		switch filepath.Ext(name) {
+		case ".php", ".phpt":
+			// All the lines are new.
+			files = append(files, changedFile{path: filepath.FromSlash(name)})
+		}
+	}
+	slices.SortFunc(files, func(a, b changedFile) int {
+		return strings.Compare(a.path, b.path)
+	})
+	return top, files, nil
+}
+
+// git runs git with args in the current directory and returns
+// its output. Errors are prefixed with cmd.
+func git(cmd string, args ...string) ([]byte, error) {
+	c := exec.Command("git", args...)
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	out, err := c.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", cmd, msg)
+		}
+		return nil, fmt.Errorf("git %s: %v", cmd, err)
+	}
+	return out, nil
+}
+
+// parseGitDiff parses the output of git diff -U0, collecting
+// the new-side line ranges of the hunks in PHP files.
+func parseGitDiff(out []byte) ([]changedFile, error) {
+	var files []changedFile
+	var cur *changedFile
+	s := bufio.NewScanner(bytes.NewReader(out))
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		line := s.Text()
+		switch {
+		case strings.HasPrefix(line, "diff "):
+			cur = nil
+		case strings.HasPrefix(line, "+++ "):
+			// Names with spaces are followed by a tab.
+			name := strings.TrimSuffix(strings.TrimPrefix(line, "+++ "), "\t")
+			if strings.HasPrefix(name, `"`) {
+				var err error
+				if name, err = strconv.Unquote(name); err != nil {
+					return nil, fmt.Errorf("git diff: bad file name %s", line)
+				}
+			}
+			name, ok := strings.CutPrefix(name, "b/")
+			if !ok {
+				continue // /dev/null
+			}
+			switch filepath.Ext(name) {
+			case ".php", ".phpt":
+				files = append(files, changedFile{path: filepath.FromSlash(name)})
+				cur = &files[len(files)-1]
+			}
+		case strings.HasPrefix(line, "@@ ") && cur != nil:
+			r, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			cur.lines = append(cur.lines, r)
+		}
+	}
+	return files, s.Err()
+}
+
+// parseHunkHeader returns the new-side lines of a hunk header
+// in the form "@@ -l,s +l,s @@". If no lines were added, e.g. only
+// deleted, the range is the line before the deletion.
+func parseHunkHeader(line string) (format.LineRange, error) {
+	f := strings.Fields(line)
+	if len(f) < 4 || !strings.HasPrefix(f[2], "+") {
+		return format.LineRange{}, fmt.Errorf("git diff: bad hunk header %q", line)
+	}
+	start, count, ok := strings.Cut(f[2][1:], ",")
+	l, err := strconv.Atoi(start)
+	if err != nil {
+		return format.LineRange{}, fmt.Errorf("git diff: bad hunk header %q", line)
+	}
+	n := 1
+	if ok {
+		if n, err = strconv.Atoi(count); err != nil {
+			return format.LineRange{}, fmt.Errorf("git diff: bad hunk header %q", line)
+		}
+	}
+	if n == 0 {
+		l, n = max(l, 1), 1
+	}
+	return format.LineRange{Start: l, End: l + n - 1}, nil
+}
+
+// walkChanged calls add for each PHP file under paths changed relative
+// to the Git revision rev, in order, skipping the files excluded by the
+// -exclude patterns, which are relative to the top-level directory
+// of the working tree, or by the configuration files. Only the changed
+// lines of each file are to be formatted.
+func walkChanged(rev string, paths []string, add func(*fileJob)) {
+	top, files, err := gitChanges(rev, paths)
+	if err != nil {
+		add(&fileJob{path: rev, err: err})
+		return
+	}
+	root, err := filepath.Abs(top)
+	if err != nil {
+		add(&fileJob{path: rev, err: err})
+		return
+	}
+	excl := ignore.Parse(filepath.ToSlash(root), nil)
+	for _, pat := range defaultExcludes {
+		excl.Add(pat)
+	}
+	for _, pat := range excludes {
+		excl.Add(pat)
+	}
+	excluded := ignore.Stack{excl}
+
+Files:
+	for _, f := range files {
+		// Check the file and all its parent directories.
+		abs, err := filepath.Abs(f.path)
+		if err != nil {
+			add(&fileJob{path: f.path, err: err})
+			continue
+		}
+		for p, isDir := abs, false; p != root && p != filepath.Dir(p); p, isDir = filepath.Dir(p), true {
+			cfg, err := findConfig(filepath.Dir(p))
+			if err != nil {
+				add(&fileJob{path: f.path, err: err})
+				continue Files
+			}
+			name := filepath.ToSlash(p)
+			if excluded.Ignored(name, isDir) || cfg.exclude.Ignored(name, isDir) {
+				continue Files
+			}
+		}
+		add(&fileJob{path: f.path, lines: f.lines})
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"mibk.dev/phpfmt/format"
+)
+
+func TestParseGitDiff(t *testing.T) {
+	out := `diff --git a/src/a.php b/src/a.php
+index 1111111..2222222 100644
+--- a/src/a.php
++++ b/src/a.php
+@@ -3 +3 @@ function a()
+-	return 1;
++	return 2;
+@@ -10,2 +10,0 @@ class B
+-	// x
+-	// y
+@@ -20,0 +19,3 @@ class B
++a
++b
++c
+diff --git a/README.md b/README.md
+--- a/README.md
++++ b/README.md
+@@ -1 +1 @@
+-x
++y
+diff --git a/my file.php b/my file.php
+--- a/my file.php	
++++ b/my file.php	
+@@ -2 +2 @@
+-$a = 1;
++$a = 2;
+diff --git a/t/new file.phpt b/t/new file.phpt
+new file mode 100644
+--- /dev/null
++++ "b/t/new file.phpt"
+@@ -0,0 +1,2 @@
++<?php
++
+`
+	got, err := parseGitDiff([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []changedFile{
+		{"src/a.php", []format.LineRange{{Start: 3, End: 3}, {Start: 10, End: 10}, {Start: 19, End: 21}}},
+		{"my file.php", []format.LineRange{{Start: 2, End: 2}}},
+		{"t/new file.phpt", []format.LineRange{{Start: 1, End: 2}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestGitChanges(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	t.Chdir(t.TempDir())
+	run := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, s string) {
+		t.Helper()
+		if err := os.WriteFile(name, []byte(s), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("init", "-q")
+	// The prefixes must not matter.
+	run("config", "diff.noprefix", "true")
+	run("config", "diff.mnemonicPrefix", "true")
+	write("a.php", "<?php\n$a = 1;\n")
+	write("my file.php", "<?php\n$a = 1;\n")
+	write(".gitignore", "ignored.php\n")
+	if err := os.Mkdir("sub", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write("sub/c.php", "<?php\n")
+	run("add", ".")
+	run("-c", "user.name=x", "-c", "user.email=x@x", "commit", "-q", "-m", "init")
+
+	write("a.php", "<?php\n$a = 1;\n$b = 2;\n")
+	write("my file.php", "<?php\n$a = 2;\n")
+	write("b.php", "<?php\n")
+	write("ignored.php", "<?php\n")
+	write("c.txt", "")
+	write("sub/c.php", "<?php\n$c = 3;\n")
+
+	tests := []struct {
+		dir   string
+		paths []string
+		top   string
+		want  []changedFile
+	}{
+		{".", nil, ".", []changedFile{
+			{"a.php", []format.LineRange{{Start: 3, End: 3}}},
+			{"b.php", nil},
+			{"my file.php", []format.LineRange{{Start: 2, End: 2}}},
+			{filepath.Join("sub", "c.php"), []format.LineRange{{Start: 2, End: 2}}},
+		}},
+		{"sub", nil, "..", []changedFile{
+			{"c.php", []format.LineRange{{Start: 2, End: 2}}},
+		}},
+		{"sub", []string{".."}, "..", []changedFile{
+			{filepath.Join("..", "a.php"), []format.LineRange{{Start: 3, End: 3}}},
+			{filepath.Join("..", "b.php"), nil},
+			{filepath.Join("..", "my file.php"), []format.LineRange{{Start: 2, End: 2}}},
+			{"c.php", []format.LineRange{{Start: 2, End: 2}}},
+		}},
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Chdir(filepath.Join(root, tt.dir))
+		top, got, err := gitChanges("HEAD", tt.paths)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if top != tt.top {
+			t.Errorf("%s %v: got top %q, want %q", tt.dir, tt.paths, top, tt.top)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %v: got %+v, want %+v", tt.dir, tt.paths, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Package diff computes line-based differences between two texts
+// and formats them as unified diffs.
+package diff
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// A Change describes the replacement of lines A[A0:A1]
+// by lines B[B0:B1]. Line indices are 0-based.
+type Change struct {
+	A0, A1 int
+	B0, B1 int
+}
+
+// SplitLines splits text into lines. Each line retains its
+// terminating newline, if any.
+func SplitLines(text []byte) []string {
+	var lines []string
+	for line := range bytes.Lines(text) {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+// Lines returns the changes needed to turn a into b.
+// The changes are sorted and do not overlap.
+func Lines(a, b []string) []Change {
+	n, m := len(a), len(b)
+	d := &differ{
+		a:   a,
+		b:   b,
+		del: make([]bool, n),
+		ins: make([]bool, m),
+	}
+	size := 2*((n+m+1)/2+1) + 1
+	d.vf = make([]int, size)
+	d.vb = make([]int, size)
+	d.compare(0, n, 0, m)
+
+	var changes []Change
+	i, j := 0, 0
+	for i < n || j < m {
+		if i < n && j < m && !d.del[i] && !d.ins[j] {
+			i++
+			j++
+			continue
+		}
+		c := Change{A0: i, B0: j}
+		for i < n && d.del[i] {
+			i++
+		}
+		for j < m && d.ins[j] {
+			j++
+		}
+		c.A1, c.B1 = i, j
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+type differ struct {
+	a, b     []string
+	del, ins []bool
+	vf, vb   []int
+}
+
+// compare marks the lines that differ between a[aLo:aHi] and b[bLo:bHi]
+// using Myers' linear space refinement.
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+	switch {
+	case aLo == aHi:
+		for j := bLo; j < bHi; j++ {
+			d.ins[j] = true
+		}
+	case bLo == bHi:
+		for i := aLo; i < aHi; i++ {
+			d.del[i] = true
+		}
+	default:
+		x, y := d.midpoint(aLo, aHi, bLo, bHi)
+		d.compare(aLo, x, bLo, y)
+		d.compare(x, aHi, y, bHi)
+	}
+}
+
+// midpoint returns a point on an optimal edit path between
+// a[aLo:aHi] and b[bLo:bHi] that lies strictly inside the edit graph.
+func (d *differ) midpoint(aLo, aHi, bLo, bHi int) (x, y int) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta&1 != 0
+	off := len(d.vf) / 2
+	vf, vb := d.vf, d.vb
+	vf[off+1], vb[off+1] = 0, 0
+	for step := 0; ; step++ {
+		for k := -step; k <= step; k += 2 {
+			var x int
+			if k == -step || k != step && vf[off+k-1] < vf[off+k+1] {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			vf[off+k] = x
+			if kb := delta - k; odd && -step < kb && kb < step && x+vb[off+kb] >= n {
+				return aLo + x, bLo + y
+			}
+		}
+		for k := -step; k <= step; k += 2 {
+			var x int
+			if k == -step || k != step && vb[off+k-1] < vb[off+k+1] {
+				x = vb[off+k+1]
+			} else {
+				x = vb[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[aHi-1-x] == d.b[bHi-1-y] {
+				x++
+				y++
+			}
+			vb[off+k] = x
+			if kf := delta - k; !odd && -step <= kf && kf <= step && x+vf[off+kf] >= n {
+				return aHi - x, bHi - y
+			}
+		}
+	}
+}
+
+// context is the number of unchanged lines shown around each hunk.
+const context = 3
+
+// Unified returns a unified diff of old and new. If the texts are
+// equal, it returns nil. The names are used in the --- and +++ headers.
+func Unified(oldName, newName string, old, new []byte) []byte {
+	a, b := SplitLines(old), SplitLines(new)
+	changes := Lines(a, b)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for len(changes) > 0 {
+		// Merge changes whose contexts would overlap.
+		n := 1
+		for n < len(changes) && changes[n].A0-changes[n-1].A1 <= 2*context {
+			n++
+		}
+		hunk := changes[:n]
+		changes = changes[n:]
+
+		first, last := hunk[0], hunk[len(hunk)-1]
+		a0 := max(first.A0-context, 0)
+		b0 := first.B0 - (first.A0 - a0)
+		a1 := min(last.A1+context, len(a))
+		b1 := last.B1 + (a1 - last.A1)
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(a0, a1), hunkRange(b0, b1))
+
+		i := a0
+		for _, c := range hunk {
+			writeLines(&out, ' ', a[i:c.A0])
+			writeLines(&out, '-', a[c.A0:c.A1])
+			writeLines(&out, '+', b[c.B0:c.B1])
+			i = c.A1
+		}
+		writeLines(&out, ' ', a[i:a1])
+	}
+	return out.Bytes()
+}
+
+func hunkRange(start, end int) string {
+	switch n := end - start; n {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, n)
+	}
+}
+
+func writeLines(out *bytes.Buffer, prefix byte, lines []string) {
+	for _, line := range lines {
+		out.WriteByte(prefix)
+		out.WriteString(line)
+		if line == "" || line[len(line)-1] != '\n' {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package diff_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"mibk.dev/phpfmt/internal/diff"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{{
+		"equal",
+		"a\nb\n",
+		"a\nb\n",
+		"",
+	}, {
+		"one change",
+		"1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+		"1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+		`--- a/x
++++ b/x
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+`,
+	}, {
+		"separate hunks",
+		"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+		"0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+		`--- a/x
++++ b/x
+@@ -1,3 +1,4 @@
++0
+ 1
+ 2
+ 3
+@@ -7,4 +8,3 @@
+ 7
+ 8
+ 9
+-10
+`,
+	}, {
+		"no newline",
+		"a\nb",
+		"a\nb\n",
+		`--- a/x
++++ b/x
+@@ -1,2 +1,2 @@
+ a
+-b
+\ No newline at end of file
++b
+`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(diff.Unified("a/x", "b/x", []byte(tt.old), []byte(tt.new)))
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinesApply(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randLines := func() []string {
+		lines := make([]string, rnd.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + rnd.Intn(4)))
+		}
+		return lines
+	}
+	for range 1000 {
+		a, b := randLines(), randLines()
+		var got []string
+		i, edits := 0, 0
+		for _, c := range diff.Lines(a, b) {
+			edits += c.A1 - c.A0 + c.B1 - c.B0
+			got = append(got, a[i:c.A0]...)
+			got = append(got, b[c.B0:c.B1]...)
+			i = c.A1
+		}
+		got = append(got, a[i:]...)
+		if strings.Join(got, "") != strings.Join(b, "") {
+			t.Fatalf("applying changes to %q gives %q, want %q", a, got, b)
+		}
+		if want := len(a) + len(b) - 2*lcs(a, b); edits != want {
+			t.Fatalf("diff of %q and %q has %d edits, want %d", a, b, edits, want)
+		}
+	}
+}
+
+// lcs returns the length of the longest common subsequence of a and b.
+func lcs(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	return dp[0][0]
+}
@@ -0,0 +1,161 @@
+// Package ignore implements matching of file paths against patterns
+// in the format of .gitignore files.
+package ignore
+
+import (
+	"bytes"
+	"path"
+	"strings"
+)
+
+// A List is a list of patterns, typically read from a single
+// .gitignore file.
+type List struct {
+	dir      string // the patterns are relative to dir
+	patterns []pattern
+}
+
+type pattern struct {
+	negate   bool
+	dirOnly  bool
+	anchored bool     // the pattern is matched against the full path
+	elems    []string // slash-separated elements of the pattern
+}
+
+// Parse parses patterns from data, one per line. The patterns
+// are relative to dir, which must be a slash-separated path,
+// as are the paths that are later matched against the list.
+func Parse(dir string, data []byte) *List {
+	l := &List{dir: path.Clean(dir)}
+	for line := range bytes.Lines(data) {
+		l.Add(string(line))
+	}
+	return l
+}
+
+// Add adds a single pattern to l. Blank lines and comments
+// are ignored.
+func (l *List) Add(line string) {
+	line = strings.TrimRight(line, "\r\n")
+	line = trimTrailingSpace(line)
+	if line == "" || line[0] == '#' {
+		return
+	}
+
+	var p pattern
+	if line[0] == '!' {
+		p.negate = true
+		line = line[1:]
+	} else if line[0] == '\\' && len(line) > 1 && (line[1] == '!' || line[1] == '#') {
+		line = line[1:]
+	}
+	if s, ok := strings.CutSuffix(line, "/"); ok {
+		p.dirOnly = true
+		line = s
+	}
+	if s, ok := strings.CutPrefix(line, "/"); ok {
+		p.anchored = true
+		line = s
+	}
+	if line == "" {
+		return
+	}
+	if strings.Contains(line, "/") {
+		p.anchored = true
+	}
+	p.elems = strings.Split(line, "/")
+	for i, e := range p.elems {
+		// Bracket expressions use ! for negation in .gitignore;
+		// path.Match uses ^.
+		p.elems[i] = strings.ReplaceAll(e, "[!", "[^")
+	}
+	l.patterns = append(l.patterns, p)
+}
+
+// trimTrailingSpace removes trailing spaces unless they are
+// escaped with a backslash.
+func trimTrailingSpace(s string) string {
+	for strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\\ ") {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// Match reports whether name is matched by a pattern in l,
+// and if so, whether it is ignored (or re-included by a negated
+// pattern). If several patterns match, the last one decides.
+func (l *List) Match(name string, isDir bool) (ignored, matched bool) {
+	if l == nil {
+		return false, false
+	}
+	rel, ok := path.Clean(name), true
+	switch l.dir {
+	case ".":
+	case "/":
+		rel, ok = strings.CutPrefix(rel, "/")
+	default:
+		rel, ok = strings.CutPrefix(rel, l.dir+"/")
+	}
+	if !ok {
+		return false, false
+	}
+	elems := strings.Split(rel, "/")
+	for _, p := range l.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.match(elems) {
+			ignored, matched = !p.negate, true
+		}
+	}
+	return ignored, matched
+}
+
+func (p *pattern) match(elems []string) bool {
+	if !p.anchored {
+		ok, _ := path.Match(p.elems[0], elems[len(elems)-1])
+		return ok
+	}
+	return matchElems(p.elems, elems)
+}
+
+func matchElems(pattern, elems []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				// A trailing /** matches everything inside.
+				return len(elems) > 0
+			}
+			for i := range elems {
+				if matchElems(pattern, elems[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(elems) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], elems[0]); !ok {
+			return false
+		}
+		pattern, elems = pattern[1:], elems[1:]
+	}
+	return len(elems) == 0
+}
+
+// A Stack is a sequence of lists in the order of increasing
+// precedence, e.g. the .gitignore files from the repository root
+// to the directory containing the path being matched.
+type Stack []*List
+
+// Ignored reports whether name is ignored by the lists in s.
+func (s Stack) Ignored(name string, isDir bool) bool {
+	for i := len(s) - 1; i >= 0; i-- {
+		if ignored, ok := s[i].Match(name, isDir); ok {
+			return ignored
+		}
+	}
+	return false
+}
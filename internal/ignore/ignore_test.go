@@ -0,0 +1,63 @@
+package ignore_test
+
+import (
+	"testing"
+
+	"mibk.dev/phpfmt/internal/ignore"
+)
+
+func TestStack(t *testing.T) {
+	root := ignore.Parse("/repo", []byte(`
+# Dependencies.
+vendor/
+/var/cache
+*.log
+!keep.log
+docs/**/*.php
+generated/**
+\#hash
+trailing\ 
+[!a]bc
+`))
+	nested := ignore.Parse("/repo/src", []byte(`
+!vendor/
+Gen*.php
+/only-here.php
+`))
+	s := ignore.Stack{root, nested}
+
+	tests := []struct {
+		name  string
+		isDir bool
+		want  bool
+	}{
+		{"/repo/vendor", true, true},
+		{"/repo/vendor", false, false},
+		{"/repo/lib/vendor", true, true},
+		{"/repo/src/vendor", true, false},
+		{"/repo/var/cache", true, true},
+		{"/repo/lib/var/cache", true, false},
+		{"/repo/error.log", false, true},
+		{"/repo/a/b/error.log", false, true},
+		{"/repo/a/keep.log", false, false},
+		{"/repo/docs/x.php", false, true},
+		{"/repo/docs/a/b/x.php", false, true},
+		{"/repo/src/docs/x.php", false, false},
+		{"/repo/generated/x.php", false, true},
+		{"/repo/generated", true, false},
+		{"/repo/#hash", false, true},
+		{"/repo/trailing ", false, true},
+		{"/repo/xbc", false, true},
+		{"/repo/abc", false, false},
+		{"/repo/src/GenFoo.php", false, true},
+		{"/repo/GenFoo.php", false, false},
+		{"/repo/src/only-here.php", false, true},
+		{"/repo/src/a/only-here.php", false, false},
+		{"/other/error.log", false, false},
+	}
+	for _, tt := range tests {
+		if got := s.Ignored(tt.name, tt.isDir); got != tt.want {
+			t.Errorf("Ignored(%q, %v) = %v, want %v", tt.name, tt.isDir, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"mibk.dev/phpfmt/format"
+)
+
+// runLSP runs a language server on in and out until the client
+// asks it to exit. It implements the document formatting requests
+// of the Language Server Protocol, and publishes syntax errors
+// and warnings as diagnostics.
+//
+// The configuration of each file is found the same way as for
+// the command line, and it is cached until the client reports
+// that a composer.json, composer.lock, or .phpfmt.json file changed.
+func runLSP(in io.Reader, out io.Writer) error {
+	s := &lspServer{
+		in:   textproto.NewReader(bufio.NewReader(in)),
+		out:  out,
+		docs: make(map[string]string),
+	}
+	return s.run()
+}
+
+type lspServer struct {
+	in  *textproto.Reader
+	out io.Writer
+
+	docs     map[string]string // by URI
+	shutdown bool
+	nextID   int
+
+	watchFiles bool // the client can dynamically register file watchers
+}
+
+// errExit is returned when the client requests the server to exit
+// after it has been shut down.
+var errExit = errors.New("exit")
+
+type lspMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *lspError) Error() string { return e.Message }
+
+// Error codes defined by JSON-RPC and LSP.
+const (
+	codeInternalError        = -32603
+	codeInvalidParams        = -32602
+	codeMethodNotFound       = -32601
+	codeInvalidRequest       = -32600
+	codeServerNotInitialized = -32002
+)
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"` // in UTF-16 code units
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+// Diagnostic severities.
+const (
+	severityError   = 1
+	severityWarning = 2
+)
+
+type lspDocument struct {
+	URI string `json:"uri"`
+}
+
+func (s *lspServer) run() error {
+	initialized := false
+	for {
+		msg, err := s.read()
+		if err == io.EOF {
+			return errors.New("unexpected end of input")
+		} else if err != nil {
+			return err
+		}
+		if msg.Method == "" {
+			// A response to our request; not interesting.
+			continue
+		}
+
+		var result any
+		switch {
+		case msg.Method == "exit":
+			if !s.shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return errExit
+		case msg.Method == "initialize":
+			initialized = true
+			result, err = s.initialize(msg.Params)
+		case !initialized:
+			err = &lspError{codeServerNotInitialized, "server not initialized"}
+		case s.shutdown:
+			err = &lspError{codeInvalidRequest, "server is shut down"}
+		default:
+			result, err = s.handle(msg.Method, msg.Params)
+		}
+		if msg.ID == nil {
+			// A notification; there is nobody to report errors to.
+			continue
+		}
+		if err := s.respond(msg.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *lspServer) handle(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "initialized":
+		if s.watchFiles {
+			return nil, s.watchConfigFiles()
+		}
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		var p struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		s.docs[p.TextDocument.URI] = p.TextDocument.Text
+		return nil, s.publishDiagnostics(p.TextDocument.URI)
+	case "textDocument/didChange":
+		var p struct {
+			TextDocument   lspDocument `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		if n := len(p.ContentChanges); n > 0 {
+			// The document is synchronized in full.
+			s.docs[p.TextDocument.URI] = p.ContentChanges[n-1].Text
+		}
+		return nil, s.publishDiagnostics(p.TextDocument.URI)
+	case "textDocument/didClose":
+		var p struct {
+			TextDocument lspDocument `json:"textDocument"`
+		}
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		delete(s.docs, p.TextDocument.URI)
+		return nil, s.notify("textDocument/publishDiagnostics", map[string]any{
+			"uri":         p.TextDocument.URI,
+			"diagnostics": []lspDiagnostic{},
+		})
+	case "textDocument/formatting":
+		var p struct {
+			TextDocument lspDocument `json:"textDocument"`
+		}
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.format(p.TextDocument.URI, nil)
+	case "textDocument/rangeFormatting":
+		var p struct {
+			TextDocument lspDocument `json:"textDocument"`
+			Range        lspRange    `json:"range"`
+		}
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		start, end := p.Range.Start.Line, p.Range.End.Line
+		if end > start && p.Range.End.Character == 0 {
+			// The selection ends at the start of a line.
+			end--
+		}
+		return s.format(p.TextDocument.URI, []format.LineRange{{Start: start + 1, End: end + 1}})
+	case "textDocument/onTypeFormatting":
+		var p struct {
+			TextDocument lspDocument `json:"textDocument"`
+			Position     lspPosition `json:"position"`
+			Ch           string      `json:"ch"`
+		}
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		line := p.Position.Line + 1
+		if p.Ch == "\n" && line > 1 {
+			// Format the line just finished.
+			line--
+		}
+		return s.format(p.TextDocument.URI, []format.LineRange{{Start: line, End: line}})
+	case "workspace/didChangeWatchedFiles":
+		var p struct {
+			Changes []struct {
+				URI string `json:"uri"`
+			} `json:"changes"`
+		}
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		for _, c := range p.Changes {
+			path, err := uriPath(c.URI)
+			if err != nil {
+				continue
+			}
+			dir := filepath.Dir(path)
+			forgetPHPVersions(dir)
+			forgetConfigs(dir)
+		}
+		for uri := range s.docs {
+			if err := s.publishDiagnostics(uri); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, &lspError{codeMethodNotFound, "method not found: " + method}
+	}
+	return nil, nil
+}
+
+func (s *lspServer) initialize(params json.RawMessage) (any, error) {
+	var p struct {
+		Capabilities struct {
+			Workspace struct {
+				DidChangeWatchedFiles struct {
+					DynamicRegistration bool `json:"dynamicRegistration"`
+				} `json:"didChangeWatchedFiles"`
+			} `json:"workspace"`
+		} `json:"capabilities"`
+	}
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	s.watchFiles = p.Capabilities.Workspace.DidChangeWatchedFiles.DynamicRegistration
+	return map[string]any{
+		"capabilities": map[string]any{
+			"textDocumentSync": map[string]any{
+				"openClose": true,
+				"change":    1, // full
+			},
+			"documentFormattingProvider":      true,
+			"documentRangeFormattingProvider": true,
+			"documentOnTypeFormattingProvider": map[string]any{
+				"firstTriggerCharacter": "}",
+				"moreTriggerCharacter":  []string{";", "\n"},
+			},
+		},
+		"serverInfo": map[string]any{"name": "phpfmt"},
+	}, nil
+}
+
+// watchConfigFiles asks the client to report changes of the files
+// the configuration of PHP files is read from.
+func (s *lspServer) watchConfigFiles() error {
+	var watchers []map[string]string
+	for _, name := range []string{"composer.json", "composer.lock", configName} {
+		watchers = append(watchers, map[string]string{"globPattern": "**/" + name})
+	}
+	s.nextID++
+	return s.write(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      s.nextID,
+		"method":  "client/registerCapability",
+		"params": map[string]any{
+			"registrations": []map[string]any{{
+				"id":              "phpfmt-watch",
+				"method":          "workspace/didChangeWatchedFiles",
+				"registerOptions": map[string]any{"watchers": watchers},
+			}},
+		},
+	})
+}
+
+// format formats the document uri, restricted to lines, if set,
+// and returns the edits. If the document cannot be formatted,
+// no edits are returned; the errors are reported as diagnostics.
+func (s *lspServer) format(uri string, lines []format.LineRange) ([]lspTextEdit, error) {
+	src, ok := s.docs[uri]
+	if !ok {
+		return nil, &lspError{codeInvalidParams, "unknown document: " + uri}
+	}
+	res, err := s.source(uri, src, lines)
+	if _, ok := err.(format.Warnings); err != nil && !ok {
+		return nil, nil
+	}
+	return textEdits(format.Edits([]byte(src), res)), nil
+}
+
+func (s *lspServer) source(uri, src string, lines []format.LineRange) ([]byte, error) {
+	path, err := uriPath(uri)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := fileConfig(io.Discard, path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Lines = lines
+	return format.Source([]byte(src), cfg)
+}
+
+func (s *lspServer) publishDiagnostics(uri string) error {
+	src, ok := s.docs[uri]
+	if !ok {
+		return nil
+	}
+	diags := []lspDiagnostic{}
+	_, err := s.source(uri, src, nil)
+	var fe *format.Error
+	switch {
+	case err == nil:
+	case errors.As(err, &fe):
+		diags = append(diags, errorDiagnostic(src, fe, severityError))
+	default:
+		if warns, ok := err.(format.Warnings); ok {
+			for _, w := range warns {
+				diags = append(diags, errorDiagnostic(src, w, severityWarning))
+			}
+			break
+		}
+		// E.g. a broken configuration file.
+		diags = append(diags, lspDiagnostic{Severity: severityError, Source: "phpfmt", Message: err.Error()})
+	}
+	return s.notify("textDocument/publishDiagnostics", map[string]any{
+		"uri":         uri,
+		"diagnostics": diags,
+	})
+}
+
+// errorDiagnostic converts e into a diagnostic spanning
+// the rest of the line it occurred on.
+func errorDiagnostic(src string, e *format.Error, severity int) lspDiagnostic {
+	line := e.Line - 1
+	text := nthLine(src, line)
+	start := lspPosition{line, format.UTF16Len(runePrefix(text, e.Column-1))}
+	end := lspPosition{line, format.UTF16Len(text)}
+	if end.Character < start.Character {
+		end = start
+	}
+	return lspDiagnostic{
+		Range:    lspRange{start, end},
+		Severity: severity,
+		Source:   "phpfmt",
+		Message:  e.Err.Error(),
+	}
+}
+
+// textEdits converts edits into LSP text edits.
+func textEdits(edits []format.Edit) []lspTextEdit {
+	pos := func(p format.Position) lspPosition {
+		return lspPosition{p.Line - 1, p.UTF16Column - 1}
+	}
+	te := make([]lspTextEdit, len(edits))
+	for i, e := range edits {
+		te[i] = lspTextEdit{lspRange{pos(e.Start), pos(e.End)}, e.NewText}
+	}
+	return te
+}
+
+func nthLine(s string, n int) string {
+	for range n {
+		_, after, ok := strings.Cut(s, "\n")
+		if !ok {
+			return ""
+		}
+		s = after
+	}
+	line, _, _ := strings.Cut(s, "\n")
+	return strings.TrimSuffix(line, "\r")
+}
+
+// runePrefix returns the first n runes of s.
+func runePrefix(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+// uriPath converts a file URI into a file path.
+func uriPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported URI: %s", uri)
+	}
+	return filepath.FromSlash(u.Path), nil
+}
+
+func unmarshalParams(params json.RawMessage, v any) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &lspError{codeInvalidParams, err.Error()}
+	}
+	return nil
+}
+
+func (s *lspServer) read() (*lspMessage, error) {
+	header, err := s.in.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("bad Content-Length: %v", err)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(s.in.R, body); err != nil {
+		return nil, err
+	}
+	msg := new(lspMessage)
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (s *lspServer) respond(id json.RawMessage, result any, err error) error {
+	if err != nil {
+		var le *lspError
+		if !errors.As(err, &le) {
+			le = &lspError{codeInternalError, err.Error()}
+		}
+		return s.write(map[string]any{"jsonrpc": "2.0", "id": id, "error": le})
+	}
+	return s.write(map[string]any{"jsonrpc": "2.0", "id": id, "result": result})
+}
+
+func (s *lspServer) notify(method string, params any) error {
+	return s.write(map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (s *lspServer) write(msg any) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(b), b)
+	return err
+}
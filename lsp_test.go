@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/textproto"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestLSP(t *testing.T) {
+	uri := "file://" + filepath.ToSlash(filepath.Join(t.TempDir(), "a.php"))
+	doc := map[string]any{"uri": uri}
+	var in bytes.Buffer
+	for i, m := range []map[string]any{
+		{"id": 1, "method": "initialize", "params": map[string]any{}},
+		{"method": "initialized", "params": map[string]any{}},
+		{"method": "textDocument/didOpen", "params": map[string]any{
+			"textDocument": map[string]any{"uri": uri, "text": "<?php\n$a=1;\n$b  =  'ž';\n"},
+		}},
+		{"id": 2, "method": "textDocument/rangeFormatting", "params": map[string]any{
+			"textDocument": doc,
+			"range":        map[string]any{"start": map[string]int{"line": 2}, "end": map[string]int{"line": 2, "character": 3}},
+		}},
+		{"method": "textDocument/didChange", "params": map[string]any{
+			"textDocument":   doc,
+			"contentChanges": []any{map[string]any{"text": "<?php\nfoo(1));\n"}},
+		}},
+		{"id": 3, "method": "textDocument/formatting", "params": map[string]any{"textDocument": doc}},
+		{"id": 4, "method": "shutdown"},
+		{"method": "exit"},
+	} {
+		m["jsonrpc"] = "2.0"
+		b, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(b), b)
+	}
+
+	var out bytes.Buffer
+	if err := runLSP(&in, &out); err != errExit {
+		t.Fatalf("got %v, want errExit", err)
+	}
+
+	var got []string
+	r := textproto.NewReader(bufio.NewReader(&out))
+	for {
+		h, err := r.ReadMIMEHeader()
+		if err != nil {
+			break
+		}
+		n, _ := strconv.Atoi(h.Get("Content-Length"))
+		b := make([]byte, n)
+		r.R.Read(b)
+		var m struct {
+			ID     int
+			Method string
+			Result json.RawMessage
+			Params json.RawMessage
+		}
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatal(err)
+		}
+		if m.ID == 1 {
+			continue // capabilities
+		}
+		if m.Method != "" {
+			got = append(got, m.Method+" "+string(m.Params))
+		} else {
+			got = append(got, fmt.Sprint(m.ID, " ", string(m.Result)))
+		}
+	}
+	want := []string{
+		`textDocument/publishDiagnostics {"diagnostics":[],"uri":"` + uri + `"}`,
+		`2 [{"range":{"start":{"line":2,"character":2},"end":{"line":2,"character":4}},"newText":" "},` +
+			`{"range":{"start":{"line":2,"character":5},"end":{"line":2,"character":7}},"newText":" "}]`,
+		`textDocument/publishDiagnostics {"diagnostics":[{"range":{"start":{"line":1,"character":6},"end":{"line":1,"character":8}},"severity":1,"source":"phpfmt","message":"unexpected )"}],"uri":"` + uri + `"}`,
+		`3 null`,
+		`4 null`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", join(got), join(want))
+	}
+}
+
+func join(s []string) string {
+	var b bytes.Buffer
+	for _, s := range s {
+		fmt.Fprintln(&b, s)
+	}
+	return b.String()
+}
@@ -2,115 +2,410 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
-	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 
 	"mibk.dev/phpfmt/format"
+	"mibk.dev/phpfmt/internal/diff"
 	"mibk.dev/phpfmt/naive"
+	"mibk.dev/phpfmt/phpver"
 )
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: phpfmt [-s] [-w] [path ...]\n")
+	fmt.Fprintf(os.Stderr, "usage: phpfmt [-d] [-l] [-s] [-v] [-w] [-watch] [-j n] [-edits json] [-report format] [-php version] [-lines start:end] [-exclude pattern] [path ...]\n")
+	fmt.Fprintf(os.Stderr, "       phpfmt [flags] -changed rev [path ...]\n")
+	fmt.Fprintf(os.Stderr, "       phpfmt [flags] [-stdin-filepath path] < file.php\n")
+	fmt.Fprintf(os.Stderr, "       phpfmt [-php version] [-s] [-verify] lsp\n")
+	fmt.Fprintf(os.Stderr, "  -cache\n")
+	fmt.Fprintf(os.Stderr, "    	remember the files that are formatted, and skip them next time\n")
+	fmt.Fprintf(os.Stderr, "    	(default true; the cache is in $XDG_CACHE_HOME/phpfmt)\n")
+	fmt.Fprintf(os.Stderr, "  -changed rev\n")
+	fmt.Fprintf(os.Stderr, "    	format only the lines of PHP files changed relative to the Git revision\n")
+	fmt.Fprintf(os.Stderr, "    	(untracked files are formatted as a whole)\n")
+	fmt.Fprintf(os.Stderr, "  -d	display diffs instead of rewriting files\n")
+	fmt.Fprintf(os.Stderr, "  -edits json\n")
+	fmt.Fprintf(os.Stderr, "    	print the minimal edits that format each file, one JSON object per file\n")
+	fmt.Fprintf(os.Stderr, "  -exclude pattern\n")
+	fmt.Fprintf(os.Stderr, "    	skip files matching the .gitignore-style pattern when walking directories\n")
+	fmt.Fprintf(os.Stderr, "    	(repeatable; vendor/ is skipped by default)\n")
+	fmt.Fprintf(os.Stderr, "  -j n	format up to n files in parallel (default GOMAXPROCS)\n")
+	fmt.Fprintf(os.Stderr, "  -l	list files whose formatting differs from phpfmt's\n")
+	fmt.Fprintf(os.Stderr, "  -lines start:end\n")
+	fmt.Fprintf(os.Stderr, "    	format only the statements overlapping the lines (repeatable;\n")
+	fmt.Fprintf(os.Stderr, "    	a single file or standard input only)\n")
+	fmt.Fprintf(os.Stderr, "  -php version\n")
+	fmt.Fprintf(os.Stderr, "    	target PHP version (e.g. 8.3), overriding composer.json and .phpfmt.json\n")
+	fmt.Fprintf(os.Stderr, "  -report format\n")
+	fmt.Fprintf(os.Stderr, "    	print a report of unformatted files and errors instead of the result\n")
+	fmt.Fprintf(os.Stderr, "    	(checkstyle, junit, sarif, or json)\n")
 	fmt.Fprintf(os.Stderr, "  -s	simplify code\n")
+	fmt.Fprintf(os.Stderr, "  -stdin-filepath path\n")
+	fmt.Fprintf(os.Stderr, "    	find the settings for standard input as if it were the file at path\n")
+	fmt.Fprintf(os.Stderr, "  -verify\n")
+	fmt.Fprintf(os.Stderr, "    	check that the formatted code has the same tokens as the source,\n")
+	fmt.Fprintf(os.Stderr, "    	except for the allowed rewrites, and report an error otherwise\n")
+	fmt.Fprintf(os.Stderr, "  -v	verbose: report the PHP version used for each file and its source\n")
 	fmt.Fprintf(os.Stderr, "  -w	write result to (source) file instead of stdout\n")
+	fmt.Fprintf(os.Stderr, "  -watch\n")
+	fmt.Fprintf(os.Stderr, "    	keep watching the files, and format them as they are modified\n")
+	fmt.Fprintf(os.Stderr, "    	(with -w, -l, or -d)\n")
 	os.Exit(2)
 }
 
 var (
+	doDiff   = flag.Bool("d", false, "display diffs")
+	parallel = flag.Int("j", runtime.GOMAXPROCS(0), "number of files formatted in parallel")
+	list     = flag.Bool("l", false, "list files whose formatting differs")
 	inPlace  = flag.Bool("w", false, "write to file")
 	simplify = flag.Bool("s", false, "simplify code")
+	verbose  = flag.Bool("v", false, "verbose mode")
+
+	changed   = flag.String("changed", "", "format only the lines changed relative to the Git revision")
+	editsFmt  = flag.String("edits", "", "print the edits in the given format instead of the result")
+	reportFmt = flag.String("report", "", "print a report in the given format instead of the result")
+	watch     = flag.Bool("watch", false, "watch the files and format them as they change")
+	useCache  = flag.Bool("cache", true, "skip the files known to be formatted")
+	verifyOut = flag.Bool("verify", false, "check that the formatted code is equivalent to the source")
+	phpFlag   = flag.String("php", "", "target PHP version")
+	stdinPath = flag.String("stdin-filepath", "", "path used to find settings for standard input")
+	excludes  stringList
+	lines     lineRanges
 )
 
+// lspFlags are the flags that apply to the lsp subcommand.
+var lspFlags = map[string]bool{"php": true, "s": true, "verify": true}
+
+// forcedPHP is the PHP version set by the -php flag, if any.
+var forcedPHP phpVersion
+
+func init() {
+	flag.Var(&excludes, "exclude", "exclude files matching the .gitignore-style pattern")
+	flag.Var(&lines, "lines", "format only the statements overlapping the line range start:end")
+}
+
+// exitCode is set to 1 if -l or -d found a file whose formatting differs.
+// If any file could not be formatted, the exit code is 2 instead.
+var exitCode = 0
+
+// errs collects the errors of all the files processed. They are
+// reported once all the other files have been formatted.
+var errs []error
+
+func report(err error) {
+	errs = append(errs, err)
+	exitCode = 2
+}
+
+// reports collects the results of all the files processed
+// if -report is set.
+var reports []fileReport
+
+func exit() {
+	if *reportFmt != "" {
+		if err := reportFormats[*reportFmt](os.Stdout, reports); err != nil {
+			log.Print(err)
+			exitCode = 2
+		}
+	}
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(exitCode)
+}
+
 func main() {
 	log.SetPrefix("phpfmt: ")
 	log.SetFlags(0)
 	flag.Usage = usage
 	flag.Parse()
 
-	if *simplify {
-		defaultOptions |= naive.Simplify
+	if *parallel < 1 {
+		log.Fatal("-j must be at least 1")
 	}
 
-	if flag.NArg() == 0 {
-		if *inPlace {
-			log.Fatal("cannot use -w with standard input")
-		}
-		if err := format.Pipe("<stdin>", os.Stdout, os.Stdin, defaultOptions); err != nil {
+	if *phpFlag != "" {
+		ver, err := phpver.Parse(*phpFlag)
+		if err != nil {
 			log.Fatal(err)
 		}
-		return
+		forcedPHP = phpVersion{ver, "-php flag"}
 	}
 
-	for _, filename := range flag.Args() {
-		f, err := os.Open(filename)
-		if err != nil {
+	// A directory named lsp can be formatted as ./lsp.
+	if flag.NArg() == 1 && flag.Arg(0) == "lsp" {
+		flag.Visit(func(f *flag.Flag) {
+			if !lspFlags[f.Name] {
+				log.Fatalf("cannot use -%s with lsp", f.Name)
+			}
+		})
+		if err := runLSP(os.Stdin, os.Stdout); err != errExit {
 			log.Fatal(err)
 		}
-		fi, err := f.Stat()
+		os.Exit(0)
+	}
+
+	switch *editsFmt {
+	case "", "json":
+	default:
+		log.Fatalf("unknown -edits format %q", *editsFmt)
+	}
+	if *editsFmt != "" && (*list || *doDiff) {
+		log.Fatal("cannot use -edits with -l or -d")
+	}
+	if *reportFmt != "" {
+		if reportFormats[*reportFmt] == nil {
+			log.Fatalf("unknown -report format %q", *reportFmt)
+		}
+		if *list || *doDiff || *editsFmt != "" {
+			log.Fatal("cannot use -report with -l, -d, or -edits")
+		}
+	}
+
+	if *watch {
+		switch {
+		case flag.NArg() == 0:
+			log.Fatal("cannot use -watch with standard input")
+		case *changed != "", len(lines) > 0, *reportFmt != "", *editsFmt != "":
+			log.Fatal("cannot use -watch with -changed, -lines, -report, or -edits")
+		case !*inPlace && !*list && !*doDiff:
+			// Printing whole files on every change is of no use.
+			log.Fatal("cannot use -watch without -w, -l, or -d")
+		}
+	}
+
+	if flag.NArg() == 0 && *changed == "" {
+		if *inPlace {
+			log.Fatal("cannot use -w with standard input")
+		}
+		src, err := io.ReadAll(os.Stdin)
 		if err != nil {
 			log.Fatal(err)
 		}
-
-		if !fi.IsDir() {
-			if err := formatFile(filename, fi.Mode().Perm(), f); err != nil {
-				log.Fatal(err)
-			}
-			continue
+		j := &fileJob{path: "<stdin>", cache: userCache()}
+		cfg := format.Config{Options: defaultOptions, PHP: forcedPHP.id, Filename: j.path, Verify: *verifyOut}
+		if *simplify {
+			cfg.Options |= naive.Simplify
+		}
+		if *stdinPath != "" {
+			j.path = *stdinPath
+			cfg, err = fileConfig(&j.log, j.path)
+		}
+		cfg.Lines = lines
+		if err == nil {
+			j.process(src, cfg)
+		} else {
+			j.err = err
+		}
+		j.finish()
+		exit()
+	}
+	if *stdinPath != "" {
+		log.Fatal("cannot use -stdin-filepath with paths")
+	}
+	if len(lines) > 0 {
+		if *changed != "" {
+			log.Fatal("cannot use -lines with -changed")
+		}
+		if flag.NArg() > 1 {
+			log.Fatal("cannot use -lines with more than one path")
 		}
+		if fi, err := os.Stat(flag.Arg(0)); err == nil && fi.IsDir() {
+			log.Fatal("cannot use -lines with a directory")
+		}
+	}
 
-		err = filepath.WalkDir(filename, func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				log.Fatal(err)
-			}
-			if d.IsDir() {
-				return nil
-			}
-			switch filepath.Ext(d.Name()) {
-			default:
-				return nil
-			case ".php", ".phpt":
-			}
+	if *watch {
+		watchFiles(flag.Args(), userCache())
+		return
+	}
 
-			f, err := os.Open(path)
-			if err != nil {
-				return err
+	// The files are formatted concurrently, but their results
+	// are reported in the order in which they were found.
+	queue := make(chan *fileJob, 4**parallel)
+	work := make(chan *fileJob)
+	for range *parallel {
+		go func() {
+			for j := range work {
+				j.run()
 			}
-			return formatFile(path, d.Type().Perm(), f)
-		})
-		if err != nil {
-			log.Fatal(err)
+		}()
+	}
+	c := userCache()
+	go func() {
+		add := func(j *fileJob) {
+			j.cache = c
+			j.done = make(chan struct{})
+			queue <- j
+			if j.err != nil {
+				close(j.done)
+				return
+			}
+			work <- j
+		}
+		if *changed != "" {
+			walkChanged(*changed, flag.Args(), add)
+		} else {
+			walkFiles(flag.Args(), add, nil)
 		}
+		close(queue)
+		close(work)
+	}()
 
+	for j := range queue {
+		<-j.done
+		j.finish()
 	}
+	exit()
+}
+
+// A fileJob is a single file to be formatted. The output is
+// buffered, so that it can be reported in walk order.
+type fileJob struct {
+	path  string
+	lines []format.LineRange // if set, only these lines are formatted
+	cache *cache
+
+	done      chan struct{} // closed when the job is finished
+	log       bytes.Buffer  // verbose messages and warnings
+	out       bytes.Buffer
+	changed   bool
+	firstDiff problemPos // where the formatted code starts to differ
+	warns     format.Warnings
+	err       error
 }
 
-func formatFile(path string, perm fs.FileMode, data io.ReadCloser) error {
-	ver, err := findMinPHPVersion(filepath.Dir(path))
+// finish reports the results of j.
+func (j *fileJob) finish() {
+	os.Stderr.Write(j.log.Bytes())
+	os.Stdout.Write(j.out.Bytes())
+	if *reportFmt != "" {
+		reports = append(reports, newFileReport(j))
+	}
+	if j.err != nil {
+		report(j.err)
+	} else if j.changed && (*list || *doDiff || *reportFmt != "") && exitCode == 0 {
+		exitCode = 1
+	}
+}
+
+func (j *fileJob) run() {
+	defer close(j.done)
+	cfg, err := fileConfig(&j.log, j.path)
 	if err != nil {
-		return err
+		j.err = err
+		return
 	}
-	opts := defaultOptions
-	if ver < targetPHPVersion {
-		opts |= naive.PHP74Compat
+	cfg.Lines = j.lines
+	src, err := os.ReadFile(j.path)
+	if err != nil {
+		j.err = err
+		return
 	}
+	j.process(src, cfg)
+}
 
-	buf := new(bytes.Buffer)
-	if err := format.Pipe(path, buf, data, opts); err != nil {
-		return err
+// fileConfig returns the formatting configuration for the file path,
+// including the targeted PHP version, taking into account the flags,
+// the configuration files, and the Composer files. In verbose mode,
+// the PHP version is reported to log.
+func fileConfig(log io.Writer, path string) (format.Config, error) {
+	dir := filepath.Dir(path)
+	cfg, err := findConfig(dir)
+	if err != nil {
+		return format.Config{}, err
 	}
-	if err := data.Close(); err != nil {
-		return err
+	ver := forcedPHP
+	if ver.id == 0 {
+		ver = cfg.php
 	}
+	if ver.id == 0 {
+		if ver, err = findMinPHPVersion(log, dir); err != nil {
+			return format.Config{}, err
+		}
+	}
+	if *verbose {
+		fmt.Fprintf(log, "%s: %v\n", path, ver)
+	}
+	opts := cfg.opts
+	if *simplify {
+		opts |= naive.Simplify
+	}
+	return format.Config{Options: opts, PHP: ver.id, MaxWidth: cfg.maxWidth, Filename: path, Verify: *verifyOut}, nil
+}
+
+// process formats src and, depending on the flags, lists the file,
+// prints a diff, writes the result back to the file, or prints it.
+// Warnings are reported to the log, but they are not errors.
+func (j *fileJob) process(src []byte, cfg format.Config) {
+	key := j.cache.key(src, cfg)
+	res := src
+	if !isFormatted(key) {
+		var err error
+		res, err = format.Source(src, cfg)
+		if warns, ok := err.(format.Warnings); ok {
+			j.warns = warns
+			for _, w := range warns {
+				fmt.Fprintf(&j.log, "phpfmt: warning: %v\n", w)
+			}
+		} else if err != nil {
+			j.err = err
+			return
+		} else if bytes.Equal(src, res) {
+			markFormatted(key)
+		}
+	}
+
+	j.changed = !bytes.Equal(src, res)
+	if j.changed && *reportFmt != "" {
+		j.firstDiff = sourcePos(src, format.Edits(src, res)[0].Start)
+	}
+	if j.changed {
+		if *list {
+			fmt.Fprintln(&j.out, j.path)
+		}
+		if *inPlace {
+			if err := writeFile(j.path, res); err != nil {
+				j.err = err
+				return
+			}
+		}
+		if *doDiff {
+			name := filepath.ToSlash(j.path)
+			j.out.Write(diff.Unified("a/"+name, "b/"+name, src, res))
+		}
+	}
+	if *editsFmt != "" {
+		edits := format.Edits(src, res)
+		if edits == nil {
+			edits = []format.Edit{}
+		}
+		enc := json.NewEncoder(&j.out)
+		enc.SetEscapeHTML(false)
+		j.err = enc.Encode(struct {
+			File  string        `json:"file"`
+			Edits []format.Edit `json:"edits"`
+		}{j.path, edits})
+	} else if !*list && !*inPlace && !*doDiff && *reportFmt == "" {
+		j.out.Write(res)
+	}
+}
 
-	if *inPlace {
-		return os.WriteFile(path, buf.Bytes(), perm)
-	} else {
-		_, err := io.Copy(os.Stdout, buf)
+// lineRanges is a flag.Value that collects repeated line ranges.
+type lineRanges []format.LineRange
+
+func (r *lineRanges) String() string { return fmt.Sprint(*r) }
+
+func (r *lineRanges) Set(s string) error {
+	lr, err := format.ParseLineRange(s)
+	if err != nil {
 		return err
 	}
+	*r = append(*r, lr)
+	return nil
 }
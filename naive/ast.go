@@ -6,11 +6,14 @@ import (
 	"mibk.dev/phpfmt/token"
 )
 
+// A File is a parsed PHP file.
 type File struct {
 	htmlPreamble *token.Token
 	block        *Block
 }
 
+// A Block is a sequence of statements or list items enclosed
+// in a pair of tokens, e.g. braces, or the whole code of a file.
 type Block struct {
 	kind           token.Type
 	open, close    token.Type
@@ -20,8 +23,12 @@ type Block struct {
 	indented       bool
 	fixComma       bool
 	nodes          []*Stmt
+
+	openPos, closePos token.Pos
 }
 
+// A Stmt is a statement or a list item. It consists of tokens
+// and blocks, e.g. the body of a function or a list of arguments.
 type Stmt struct {
 	kind       token.Type
 	isLabel    bool
@@ -30,6 +37,103 @@ type Stmt struct {
 	nodes      []any
 }
 
+// HTMLPreamble returns the inline HTML before the first PHP open tag,
+// if there is any.
+func (f *File) HTMLPreamble() (tok token.Token, ok bool) {
+	if f.htmlPreamble == nil {
+		return token.Token{}, false
+	}
+	return *f.htmlPreamble, true
+}
+
+// Block returns the block of the PHP code of f, which starts
+// after the first open tag and ends with EOF.
+func (f *File) Block() *Block { return f.block }
+
+// Kind returns the type of the token that decides what the block is,
+// e.g. token.Function for the parameters and the body of a function,
+// token.Match for the arms of a match, or token.Illegal if unknown.
+func (b *Block) Kind() token.Type { return b.kind }
+
+// Open returns the type of the token that opens the block:
+// token.Lbrace, token.Lparen, token.Lbrack, or token.OpenTag.
+func (b *Block) Open() token.Type { return b.open }
+
+// Close returns the type of the token that closes the block:
+// token.Rbrace, token.Rparen, token.Rbrack, or token.EOF.
+func (b *Block) Close() token.Type { return b.close }
+
+// OpenPos returns the position of the token that opens the block.
+func (b *Block) OpenPos() token.Pos { return b.openPos }
+
+// ClosePos returns the position of the token that closes the block.
+func (b *Block) ClosePos() token.Pos { return b.closePos }
+
+// Multiline reports whether the block is printed on more lines,
+// i.e. whether a newline follows its opening token in the source,
+// or it is in braces, which, with a few exceptions, are always
+// multiline.
+func (b *Block) Multiline() bool { return b.multiline }
+
+// LineComment returns the line comment that directly follows
+// the opening token of the block, if there is any.
+func (b *Block) LineComment() (tok token.Token, ok bool) {
+	if b.commentTag == nil {
+		return token.Token{}, false
+	}
+	return *b.commentTag, true
+}
+
+// Stmts returns the statements, or list items, of the block.
+func (b *Block) Stmts() []*Stmt { return slices.Clone(b.nodes) }
+
+// Kind returns the type of the first token that decides what
+// the statement is, e.g. token.If or token.Class, or token.Illegal
+// for other statements, e.g. expressions.
+func (s *Stmt) Kind() token.Type { return s.kind }
+
+// IsLabel reports whether s is a label, e.g. a case of a switch,
+// or a label for goto.
+func (s *Stmt) IsLabel() bool { return s.isLabel }
+
+// Multiline reports whether the statement spans more lines
+// in the source.
+func (s *Stmt) Multiline() bool { return s.multiline }
+
+// Nodes returns the nodes of the statement in the source order.
+// Each node is a token.Token, a *Block, or a *TernaryMiddle.
+//
+// The tokens include whitespace and comments, and keep their
+// positions in the source. Reserved words used as names have
+// the type token.Ident. A type cast is a single token of the
+// type [CastToken], and the code left unformatted by a directive
+// is a single token of the type [VerbatimToken].
+func (s *Stmt) Nodes() []any { return exportNodes(s.nodes) }
+
+// Nodes returns the nodes of the middle operand,
+// as [Stmt.Nodes] does.
+func (m *TernaryMiddle) Nodes() []any { return exportNodes(m.nodes) }
+
+// exportNodes returns a copy of nodes with the tokens
+// disguised by the parser restored to their types.
+func exportNodes(nodes []any) []any {
+	nodes = slices.Clone(nodes)
+	for i, n := range nodes {
+		if tok, ok := n.(token.Token); ok && tok.Type == token.At {
+			switch tok.Text {
+			case "&":
+				tok.Type = token.BitAnd
+			case "+":
+				tok.Type = token.Add
+			case "-":
+				tok.Type = token.Sub
+			}
+			nodes[i] = tok
+		}
+	}
+	return nodes
+}
+
 func (b *Block) oneliner() bool {
 	return b.open == token.Lbrace && !b.multiline && !isFetchOperator(b.kind) && len(b.nodes) > 0
 }
@@ -38,7 +142,7 @@ func (s *Stmt) lastType() token.Type {
 	for _, x := range slices.Backward(s.nodes) {
 		tok, ok := x.(token.Token)
 		if !ok {
-			if _, ok := x.(*ternaryMiddle); ok {
+			if _, ok := x.(*TernaryMiddle); ok {
 				return token.Colon
 			}
 			// TODO: A better one?
@@ -52,7 +156,9 @@ func (s *Stmt) lastType() token.Type {
 	return token.Illegal
 }
 
-type ternaryMiddle struct {
+// A TernaryMiddle is the middle operand of the ternary
+// operator, between ? and :, in a Stmt.
+type TernaryMiddle struct {
 	stmtAlreadyIndented bool
 	extraIndented       *indentation
 	doesContinue        *bool
@@ -0,0 +1,190 @@
+package naive
+
+import (
+	"strings"
+
+	"mibk.dev/phpfmt/token"
+)
+
+// A collapser joins the multiline ( and [ blocks of the statements
+// in multiline blocks onto single lines where the statements still
+// fit within maxWidth. A statement that does not fit is left as it
+// is, and its parts on separate lines are tried instead.
+type collapser struct {
+	maxWidth   int
+	newPrinter func() *printer // for measuring statements
+}
+
+func (c *collapser) block(b *Block, indent indentation) {
+	if b.indented {
+		indent++
+	}
+	for _, s := range b.nodes {
+		if b.multiline || b.open == token.OpenTag {
+			c.stmt(b, s, indent)
+		} else {
+			c.nodes(s.nodes, indent)
+		}
+	}
+}
+
+func (c *collapser) nodes(nodes []any, indent indentation) {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *Block:
+			c.block(n, indent)
+		case *TernaryMiddle:
+			c.nodes(n.nodes, indent)
+		}
+	}
+}
+
+// stmt collapses the blocks of s, a statement of the multiline
+// block b, printed at the indentation indent.
+func (c *collapser) stmt(b *Block, s *Stmt, indent indentation) {
+	saved := copyStmt(s)
+	collapseNodes(s.nodes)
+	if c.fits(b, s, indent) {
+		return
+	}
+	*s = *saved
+	c.nodes(s.nodes, indent)
+}
+
+// fits reports whether the lines of s, a statement of b, are not
+// wider than c.maxWidth when printed at the indentation indent.
+func (c *collapser) fits(b *Block, s *Stmt, indent indentation) bool {
+	p := c.newPrinter()
+	p.blockType, p.blockOpen, p.multiline = b.kind, b.open, true
+	p.indent = indent
+	p.print(indent, s)
+	fits := true
+	eachLine(p.tokens, func(_, width int) {
+		if width > c.maxWidth {
+			fits = false
+		}
+	})
+	return fits && p.err == nil
+}
+
+// collapse joins the ( and [ blocks within b (and b itself,
+// if it is such a block) onto single lines, unless they contain
+// comments or other code that needs more lines, e.g. a multiline
+// function body. It reports whether b fits on a single line.
+func collapse(b *Block) bool {
+	oneLine := b.commentTag == nil
+	for _, s := range b.nodes {
+		if !collapseNodes(s.nodes) {
+			oneLine = false
+		}
+	}
+	switch {
+	case b.open == token.Lparen, b.open == token.Lbrack:
+		if oneLine {
+			joinLines(b)
+		}
+		return oneLine
+	case b.open == token.Lbrace:
+		return oneLine && !b.multiline
+	}
+	return false
+}
+
+func collapseNodes(nodes []any) bool {
+	oneLine := true
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case token.Token:
+			switch {
+			case n.Type == token.Whitespace:
+			case n.Type == token.Comment, n.Type == token.DocComment,
+				n.Type == VerbatimToken,
+				strings.Contains(n.Text, "\n"):
+				oneLine = false
+			}
+		case *Block:
+			if !collapse(n) {
+				oneLine = false
+			}
+		case *TernaryMiddle:
+			if !collapseNodes(n.nodes) {
+				oneLine = false
+			}
+		}
+	}
+	return oneLine
+}
+
+// joinLines puts b, with all its blocks, on a single line.
+func joinLines(b *Block) {
+	b.multiline, b.indented, b.offsetEndParen = false, false, false
+	for _, s := range b.nodes {
+		s.multiline, s.trailingNL = false, false
+		joinNodes(s.nodes)
+	}
+}
+
+func joinNodes(nodes []any) {
+	for i, n := range nodes {
+		switch n := n.(type) {
+		case token.Token:
+			if n.Type == token.Whitespace && strings.Contains(n.Text, "\n") {
+				n.Text = " "
+				nodes[i] = n
+			}
+		case *Block:
+			joinLines(n)
+		case *TernaryMiddle:
+			joinNodes(n.nodes)
+		}
+	}
+}
+
+// copyNode returns a deep copy of node, so that it can be changed
+// without changing the caller's tree. Other values are returned
+// as they are.
+func copyNode(node any) any {
+	switch n := node.(type) {
+	case *File:
+		f := *n
+		f.block = copyBlock(n.block)
+		return &f
+	case *Block:
+		return copyBlock(n)
+	case *Stmt:
+		return copyStmt(n)
+	}
+	return node
+}
+
+func copyBlock(b *Block) *Block {
+	c := *b
+	c.nodes = make([]*Stmt, len(b.nodes))
+	for i, s := range b.nodes {
+		c.nodes[i] = copyStmt(s)
+	}
+	return &c
+}
+
+func copyStmt(s *Stmt) *Stmt {
+	c := *s
+	c.nodes = copyNodes(s.nodes)
+	return &c
+}
+
+func copyNodes(nodes []any) []any {
+	c := make([]any, len(nodes))
+	for i, n := range nodes {
+		switch n := n.(type) {
+		case *Block:
+			c[i] = copyBlock(n)
+		case *TernaryMiddle:
+			t := *n
+			t.nodes = copyNodes(n.nodes)
+			c[i] = &t
+		default:
+			c[i] = n
+		}
+	}
+	return c
+}
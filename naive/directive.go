@@ -0,0 +1,141 @@
+package naive
+
+import (
+	"strings"
+
+	"mibk.dev/phpfmt/token"
+)
+
+// A Directive is a line comment that controls formatting.
+// Directives are recognized only on their own lines
+// at the beginning of a statement.
+type Directive int
+
+const (
+	NoDirective Directive = iota
+
+	// DirectiveOff (// phpfmt:off) leaves the code up to
+	// the matching DirectiveOn in the same block unformatted.
+	// Without one, the code is left unformatted up to the end
+	// of the block.
+	DirectiveOff
+
+	// DirectiveOn (// phpfmt:on) ends a DirectiveOff region.
+	DirectiveOn
+
+	// DirectiveIgnore (// phpfmt:ignore) leaves the next
+	// statement, including its blocks, unformatted. This includes
+	// all the clauses of if and try statements, e.g. else.
+	DirectiveIgnore
+)
+
+// ParseDirective returns the directive of the comment tok,
+// or NoDirective if tok is not one. Any text after the directive,
+// separated by a space, is ignored.
+func ParseDirective(tok token.Token) Directive {
+	if !isLineComment(tok) {
+		return NoDirective
+	}
+	text := strings.TrimPrefix(strings.TrimPrefix(tok.Text, "//"), "#")
+	name, _, _ := strings.Cut(strings.TrimSpace(text), " ")
+	switch name {
+	case "phpfmt:off":
+		return DirectiveOff
+	case "phpfmt:on":
+		return DirectiveOn
+	case "phpfmt:ignore":
+		return DirectiveIgnore
+	}
+	return NoDirective
+}
+
+// parseVerbatim parses the code controlled by the directive d, which
+// is the current token, into a single meta token that is printed as it
+// is, and appends it to s. It reports whether s ends after the token.
+func (p *parser) parseVerbatim(s *Stmt, d Directive, separators []token.Type) bool {
+	var b strings.Builder
+	p.rec = &b
+	pos := p.tok.Pos
+	p.next()
+	switch d {
+	case DirectiveOff:
+		depth := 0
+	Loop:
+		for {
+			switch p.tok.Type {
+			case token.EOF:
+				break Loop
+			case token.Lparen, token.Lbrack, token.Lbrace:
+				depth++
+			case token.Rparen, token.Rbrack, token.Rbrace:
+				if depth == 0 {
+					// The end of the enclosing block.
+					break Loop
+				}
+				depth--
+			case token.Comment:
+				if depth == 0 && ParseDirective(p.tok) == DirectiveOn {
+					p.next()
+					break Loop
+				}
+			}
+			p.next()
+		}
+	case DirectiveIgnore:
+		p.parseStmt(separators...)
+		for p.clauseFollows() {
+			p.parseStmt(separators...)
+		}
+	}
+	p.rec = nil
+
+	// Leave the whitespace before the end of the block
+	// to the block.
+	text := b.String()
+	code := strings.TrimRight(text, " \t\r\n")
+	s.nodes = append(s.nodes, token.Token{Type: VerbatimToken, Text: code, Pos: pos})
+	if strings.Contains(code, "\n") {
+		s.multiline = true
+	}
+	if ws := text[len(code):]; ws != "" {
+		s.nodes = append(s.nodes, token.Token{Type: token.Whitespace, Text: ws})
+		return false
+	}
+	return true
+}
+
+// clauseFollows reports whether the next token, not counting
+// whitespace, starts a clause that continues the statement parsed
+// last, i.e. else (or elseif), catch, or finally.
+func (p *parser) clauseFollows() bool {
+	tok := p.tok
+	if tok.Type == token.Whitespace {
+		if p.alt == nil {
+			next := p.scan.Next()
+			p.alt = &next
+		}
+		tok = *p.alt
+	}
+	return isClause(tok.Type)
+}
+
+func isClause(typ token.Type) bool {
+	return typ == token.Else || typ == token.Catch || typ == token.Finally
+}
+
+// onlyComments reports whether nodes consist only
+// of whitespace and comments.
+func onlyComments(nodes []any) bool {
+	for _, n := range nodes {
+		tok, ok := n.(token.Token)
+		if !ok {
+			return false
+		}
+		switch tok.Type {
+		case token.Whitespace, token.Comment, token.DocComment:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -6,4 +6,7 @@
 // but it normalises the output: spacing, line breaks, and other cosmetic trivia
 // may differ from the original source, although the program’s behaviour
 // is preserved.
+//
+// The syntax tree can be traversed using [Walk] or [Inspect], and examined,
+// but not modified, through the methods of its nodes, e.g. [Stmt.Nodes].
 package naive
@@ -0,0 +1,88 @@
+package naive
+
+import (
+	"slices"
+	"strings"
+
+	"mibk.dev/phpfmt/token"
+)
+
+// ExpandLines expands the line range from lo to hi (1-based, inclusive)
+// so that it starts and ends on statement boundaries. If the range lies
+// within the braces of a single statement, e.g. inside a function body,
+// only the statements in that block that overlap the range are included.
+func (f *File) ExpandLines(lo, hi int) (int, int) {
+	if f.block == nil {
+		return lo, hi
+	}
+	return f.block.expandLines(lo, hi)
+}
+
+func (b *Block) expandLines(lo, hi int) (int, int) {
+	var overlap []*Stmt
+	nlo, nhi := lo, hi
+	for _, s := range b.nodes {
+		first, last, ok := s.lines()
+		if !ok || last < lo || first > hi {
+			continue
+		}
+		overlap = append(overlap, s)
+		nlo, nhi = min(nlo, first), max(nhi, last)
+	}
+	if len(overlap) == 1 {
+		for _, n := range overlap[0].nodes {
+			sub, ok := n.(*Block)
+			if ok && sub.open == token.Lbrace && sub.openPos.Line < lo && hi < sub.closePos.Line {
+				return sub.expandLines(lo, hi)
+			}
+		}
+	}
+	return nlo, nhi
+}
+
+// lines returns the first and the last line of s, ignoring
+// surrounding whitespace. It reports false if s has no such lines.
+func (s *Stmt) lines() (first, last int, ok bool) {
+	first, ok = firstLine(s.nodes)
+	if !ok {
+		return 0, 0, false
+	}
+	last, _ = lastLine(s.nodes)
+	return first, last, true
+}
+
+func firstLine(nodes []any) (int, bool) {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case token.Token:
+			if n.Type != token.Whitespace {
+				return n.Pos.Line, true
+			}
+		case *Block:
+			return n.openPos.Line, true
+		case *TernaryMiddle:
+			if line, ok := firstLine(n.nodes); ok {
+				return line, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func lastLine(nodes []any) (int, bool) {
+	for _, n := range slices.Backward(nodes) {
+		switch n := n.(type) {
+		case token.Token:
+			if n.Type != token.Whitespace {
+				return n.Pos.Line + strings.Count(n.Text, "\n"), true
+			}
+		case *Block:
+			return n.closePos.Line, true
+		case *TernaryMiddle:
+			if line, ok := lastLine(n.nodes); ok {
+				return line, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -7,7 +7,7 @@ const obviousPrecLevel = 10
 // See https://www.php.net/manual/en/language.operators.precedence.php
 var opTable = [...][]token.Type{
 	1: {token.Pow},
-	2: {metaTokenCast},
+	2: {CastToken},
 	4: {token.Not},
 	5: {token.Mul, token.Quo, token.Rem},
 	6: {token.Add, token.Sub},
@@ -59,7 +59,7 @@ func (p *printer) analyseOps(tokens []any) (max int) {
 
 	var last token.Type
 	for _, tok := range tokens {
-		if _, ok := tok.(*ternaryMiddle); ok {
+		if _, ok := tok.(*TernaryMiddle); ok {
 			break
 		}
 		tok, ok := tok.(token.Token)
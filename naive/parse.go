@@ -7,11 +7,15 @@ import (
 	"slices"
 	"strings"
 
+	"mibk.dev/phpfmt/phpver"
 	"mibk.dev/phpfmt/token"
 )
 
+// Types of the tokens that the parser creates in place
+// of one or more tokens of the source code.
 const (
-	metaTokenCast = 1024 + iota
+	CastToken     token.Type = 1024 + iota // a type cast, e.g. (int)
+	VerbatimToken                          // code left unformatted by a directive
 )
 
 // SyntaxError records an error and the position it occurred on.
@@ -31,12 +35,16 @@ type parser struct {
 	tok       token.Token
 	alt       *token.Token // on backup
 	blockKind token.Type
+
+	afterNL bool             // the last token ends a line
+	rec     *strings.Builder // records the text of tokens if not nil
 }
 
-// Parse parses a single PHP file. If an error occurs while parsing
-// (except io errors), the returned error will be of type *SyntaxError.
-func Parse(r io.Reader, php74Compat bool) (*File, error) {
-	p := &parser{scan: token.NewScanner(r, php74Compat)}
+// Parse parses a single PHP file written for the PHP version ver.
+// If an error occurs while parsing (except io errors), the returned
+// error will be of type *SyntaxError.
+func Parse(r io.Reader, ver phpver.Version) (*File, error) {
+	p := &parser{scan: token.NewScanner(r, ver)}
 	p.next() // init
 	file := p.parseFile()
 	if p.err != nil {
@@ -49,11 +57,15 @@ func (p *parser) next() {
 	if p.tok.Type == token.EOF {
 		return
 	}
+	p.afterNL = p.tok.Type == token.Whitespace && strings.Contains(p.tok.Text, "\n")
+	if p.rec != nil {
+		p.rec.WriteString(p.tok.Text)
+	}
 	if p.alt != nil {
 		p.tok, p.alt = *p.alt, nil
-		return
+	} else {
+		p.tok = p.scan.Next()
 	}
-	p.tok = p.scan.Next()
 	if p.tok.Type == token.EOF && p.err == nil {
 		err := p.scan.Err()
 		if se, ok := err.(*token.ScanError); ok {
@@ -91,12 +103,14 @@ func (p *parser) parseFile() *File {
 	if text := p.tok; p.got(token.InlineHTML) {
 		file.htmlPreamble = &text
 	}
+	pos := p.tok.Pos
 	if !p.got(token.OpenTag) {
 		p.errorf("expecting %v, found %v", token.OpenTag, p.tok)
 		return nil
 	}
 
 	file.block = p.parseBlock(token.Illegal, token.OpenTag)
+	file.block.openPos = pos
 	file.block.indented = false
 	file.block.offsetEndParen = false
 	return file
@@ -186,6 +200,7 @@ func (p *parser) parseBlock(kind, open token.Type) (b *Block) {
 
 		switch typ := p.tok.Type; typ {
 		case b.close:
+			b.closePos = p.tok.Pos
 			b.offsetEndParen = b.indented && stmt.trailingNL
 			p.next()
 			return b
@@ -263,14 +278,16 @@ func (p *parser) parseStmt(separators ...token.Type) (s *Stmt) {
 				// Let's use something that always places { on the same line.
 				nextBlock = token.Fn
 			}
+			pos := p.tok.Pos
 			p.next()
 			sub := p.parseBlock(block, typ)
+			sub.openPos = pos
 			if sub.close == token.Rparen && len(sub.nodes) == 1 {
 				stmt := sub.nodes[0]
 				if len(stmt.nodes) == 1 {
 					tok, ok := stmt.nodes[0].(token.Token)
 					if ok && canUseAsCast(tok) {
-						s.nodes = append(s.nodes, token.Token{Type: metaTokenCast, Text: "(" + tok.Text + ")"})
+						s.nodes = append(s.nodes, token.Token{Type: CastToken, Text: "(" + tok.Text + ")", Pos: pos})
 						break
 					}
 				}
@@ -278,8 +295,10 @@ func (p *parser) parseStmt(separators ...token.Type) (s *Stmt) {
 			s.nodes = append(s.nodes, sub)
 		case token.Lbrace, token.Lbrack:
 			s.kind = cmp.Or(s.kind, typ)
+			pos := p.tok.Pos
 			p.next()
 			sub := p.parseBlock(nextBlock, typ)
+			sub.openPos = pos
 			s.nodes = append(s.nodes, sub)
 			if typ == token.Lbrace {
 				// In most cases, } marks an end of a statement.
@@ -304,7 +323,7 @@ func (p *parser) parseStmt(separators ...token.Type) (s *Stmt) {
 			p.next()
 			m := p.parseStmt(token.Colon, token.Semicolon, token.Comma)
 			if p.got(token.Colon) {
-				s.nodes = append(s.nodes, &ternaryMiddle{nodes: m.nodes})
+				s.nodes = append(s.nodes, &TernaryMiddle{nodes: m.nodes})
 			} else {
 				s.nodes = append(s.nodes, qmark)
 				s.nodes = append(s.nodes, m.nodes...)
@@ -348,6 +367,16 @@ func (p *parser) parseStmt(separators ...token.Type) (s *Stmt) {
 			}
 			s.nodes = append(s.nodes, p.tok)
 			p.next()
+		case token.Comment:
+			if d := ParseDirective(p.tok); (d == DirectiveOff || d == DirectiveIgnore) &&
+				p.afterNL && onlyComments(s.nodes) {
+				if p.parseVerbatim(s, d, separators) {
+					return s
+				}
+				break
+			}
+			s.nodes = append(s.nodes, p.tok)
+			p.next()
 		case token.Whitespace:
 			if strings.Contains(p.tok.Text, "\n") {
 				s.multiline = true
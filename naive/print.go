@@ -9,6 +9,7 @@ import (
 	"strings"
 	"text/tabwriter"
 
+	"mibk.dev/phpfmt/phpver"
 	"mibk.dev/phpfmt/token"
 )
 
@@ -25,36 +26,80 @@ const (
 	// constants true, false, and null to lowercase.
 	LowercaseKeywords
 
-	// PHP74Compat switches formatting to PHP 7.4 compatibility mode.
-	//
-	// - This will remove support for trailing comma.
-	// - The concat operator (.) is formatted according to PHP 7.4 precedence rules.
-	PHP74Compat
-
 	// Simplify enables code simplification rewrites. Currently this
 	// converts double-quoted strings to single-quoted when no
 	// interpolation or special escape sequences are used.
 	Simplify
 
+	// CollapseLists joins multiline ( and [ blocks, e.g. argument lists
+	// and arrays, onto a single line if they contain no comments and
+	// fit within Config.MaxWidth. It has no effect without MaxWidth.
+	CollapseLists
+
 	// Standard is the default, “standard” formatting style.
 	Standard = TrailingComma | AlignColumns | LowercaseKeywords
 )
 
-// Fprint pretty-prints an AST node to w.
+// A Config controls the output of Fprint.
+type Config struct {
+	Options Options
+
+	// PHP is the PHP version the output is formatted for.
+	// It decides, e.g., where trailing commas may be added,
+	// or the precedence of the concat operator (.).
+	// The zero value means the latest version.
+	PHP phpver.Version
+
+	// MaxWidth is the maximum width of lines, counting an indentation
+	// level as 4 columns. Statements with longer lines are broken into
+	// more lines where possible. Zero means no limit.
+	MaxWidth int
+}
+
+// Fprint pretty-prints an AST node to w, formatted
+// for the latest PHP version.
 func Fprint(w io.Writer, node any, options Options) error {
+	return (&Config{Options: options}).Fprint(w, node)
+}
+
+// Fprint pretty-prints an AST node to w. It does not change node.
+func (c *Config) Fprint(w io.Writer, node any) error {
+	options := c.Options
 	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', tabwriter.StripEscape)
 
 	concatPrec := opPrec[token.Concat]
-	if options&PHP74Compat > 0 {
-		options &= ^TrailingComma
-
+	if !c.PHP.AtLeast(phpver.PHP80) {
 		// Before PHP 8.0, the concatenation operator
 		// had the same precedence as + or -.
 		concatPrec = 6
 	}
 
-	p := &printer{options: options, concatPrec: concatPrec}
+	newPrinter := func() *printer {
+		return &printer{options: options, php: c.PHP, concatPrec: concatPrec, wrapping: c.MaxWidth > 0}
+	}
+	if c.MaxWidth > 0 {
+		// Collapsing and breaking lines change the tree.
+		node = copyNode(node)
+	}
+	if options&CollapseLists > 0 && c.MaxWidth > 0 {
+		cl := &collapser{maxWidth: c.MaxWidth, newPrinter: newPrinter}
+		switch node := node.(type) {
+		case *File:
+			cl.block(node.block, 0)
+		case *Block:
+			cl.block(node, 0)
+		}
+	}
+
+	p := newPrinter()
 	p.print(node)
+	if c.MaxWidth > 0 {
+		w := &wrapper{maxWidth: c.MaxWidth, states: make(map[*Stmt]*wrapState)}
+		for p.err == nil && w.wrap(p) {
+			p = newPrinter()
+			p.print(node)
+		}
+	}
 	if p.err != nil {
 		return p.err
 	}
@@ -114,8 +159,9 @@ func Fprint(w io.Writer, node any, options Options) error {
 type indentation int
 
 type printer struct {
-	options   Options
-	concatPrec int // overrides opPrec[token.Concat] for PHP 7.4 compat
+	options    Options
+	php        phpver.Version
+	concatPrec int // overrides opPrec[token.Concat] before PHP 8.0
 
 	tokens []any
 	err    error // sticky
@@ -131,6 +177,9 @@ type printer struct {
 	maxPrec             int
 	skipSpaceAfterBlock bool
 
+	wrapping bool       // record spans for Config.MaxWidth
+	spans    []stmtSpan // in the order they are finished
+
 	blockCtx
 }
 
@@ -162,7 +211,7 @@ func (p *printer) print(args ...any) {
 			p.printFile(arg)
 		case *Block:
 			p.printBlock(arg)
-		case *ternaryMiddle:
+		case *TernaryMiddle:
 			p.printTernary(arg)
 		case *Stmt:
 			p.printStmt(arg)
@@ -203,6 +252,7 @@ func (p *printer) printFile(arg *File) {
 
 func (p *printer) printBlock(arg *Block) {
 	p.lastBlock = arg.kind
+	commaAllowed := p.trailingCommaAllowed(arg)
 	switch arg.open {
 	case token.Lparen:
 		switch last := p.lastToken(); last {
@@ -289,7 +339,11 @@ func (p *printer) printBlock(arg *Block) {
 		if isClassLike && blankBefore[i] {
 			p.ensureBlankLine = true
 		}
+		start := len(p.tokens)
 		p.print(x)
+		if p.wrapping && p.multiline {
+			p.spans = append(p.spans, stmtSpan{x, start, len(p.tokens)})
+		}
 	}
 	p.blockCtx = backup
 
@@ -306,13 +360,13 @@ func (p *printer) printBlock(arg *Block) {
 	if arg.oneliner() {
 		p.ensureSpace()
 	} else if arg.multiline || arg.offsetEndParen {
-		if p.options&TrailingComma > 0 && arg.fixComma && len(arg.nodes) > 0 {
+		if p.options&TrailingComma > 0 && arg.fixComma && commaAllowed && len(arg.nodes) > 0 {
 			p.removeLast(space)
 			c := p.removeLast(token.Comment)
 			p.removeLast(space)
 			p.removeLast(nextcol)
 			p.removeLast(token.Comma)
-			if p.lastIsToken() {
+			if p.lastIsToken() && p.lastToken() != VerbatimToken {
 				p.print(token.Comma)
 			}
 			if c != nil {
@@ -352,7 +406,26 @@ func (p *printer) printBlock(arg *Block) {
 	p.skipSpaceBeforeParen = false
 }
 
-func (p *printer) printTernary(arg *ternaryMiddle) {
+// trailingCommaAllowed reports whether the targeted PHP version
+// allows a trailing comma in arg, which is about to be printed.
+func (p *printer) trailingCommaAllowed(arg *Block) bool {
+	if arg.open != token.Lparen {
+		// Arrays and match arms.
+		return true
+	}
+	if arg.kind == token.Function {
+		// Parameter lists and closure use lists.
+		return p.php.AtLeast(phpver.PHP80)
+	}
+	switch strings.ToLower(p.lastTok().Text) {
+	case "array", "list":
+		return true
+	}
+	// Calls.
+	return p.php.AtLeast(phpver.PHP73)
+}
+
+func (p *printer) printTernary(arg *TernaryMiddle) {
 	p.removeLast(space)
 	p.print(space, token.Qmark, space)
 	p.skipSpaceBeforeParen = false
@@ -511,7 +584,7 @@ func (p *printer) printStmt(arg *Stmt) {
 				fatArrow = false
 				mightContinue = true
 			}
-		case *ternaryMiddle:
+		case *TernaryMiddle:
 			p.maxPrec = p.analyseOps(x.nodes)
 			recalcPrecAfter = true
 			x.stmtAlreadyIndented = doesContinue || stmtReallyIndented
@@ -656,7 +729,11 @@ func (p *printer) printToken(arg token.Token) {
 		fallthrough
 	case token.Qmark, token.BitNot, token.At, token.Not, token.Dollar, token.Ellipsis:
 		p.skipNextSpace = true
-	case metaTokenCast:
+	case VerbatimToken:
+		// Printed as it is, but like a block
+		// if it ends with one.
+		printSpaceAfter = strings.HasSuffix(arg.Text, "}")
+	case CastToken:
 		// TODO: The ] in isPostfixTarget feels like a hack.
 		if isPostfixTarget(p.lastToken()) {
 			p.removeLast(space)
@@ -724,6 +801,10 @@ func (p *printer) printToken(arg token.Token) {
 }
 
 func (p *printer) lastToken() token.Type {
+	return p.lastTok().Type
+}
+
+func (p *printer) lastTok() token.Token {
 	for _, tok := range slices.Backward(p.tokens) {
 		if _, ok := tok.(whitespace); ok {
 			continue
@@ -735,9 +816,9 @@ func (p *printer) lastToken() token.Type {
 		if !ok {
 			break
 		}
-		return t.Type
+		return t
 	}
-	return token.Illegal
+	return token.Token{Type: token.Illegal}
 }
 
 func (p *printer) lastIsToken() bool {
@@ -880,7 +961,7 @@ func spaceAfter(typ token.Type) bool {
 type memberCat int
 
 const (
-	catUnknown  memberCat = iota
+	catUnknown memberCat = iota
 	catUse
 	catConst
 	catProperty
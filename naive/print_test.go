@@ -0,0 +1,34 @@
+package naive_test
+
+import (
+	"strings"
+	"testing"
+
+	"mibk.dev/phpfmt/naive"
+	"mibk.dev/phpfmt/phpver"
+)
+
+func TestFprintKeepsTree(t *testing.T) {
+	const src = `<?php
+foo(
+	$a,
+);
+$x = $aaaaaaaa . bar($bbbbbbbb, $cccccccc) . $dddddddd;
+`
+	f, err := naive.Parse(strings.NewReader(src), phpver.Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fprint := func(cfg naive.Config) string {
+		var b strings.Builder
+		if err := cfg.Fprint(&b, f); err != nil {
+			t.Fatal(err)
+		}
+		return b.String()
+	}
+	want := fprint(naive.Config{Options: naive.Standard})
+	fprint(naive.Config{Options: naive.Standard | naive.CollapseLists, MaxWidth: 30})
+	if got := fprint(naive.Config{Options: naive.Standard}); got != want {
+		t.Errorf("Fprint changed the tree; got\n%s\nwant\n%s", got, want)
+	}
+}
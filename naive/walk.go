@@ -0,0 +1,87 @@
+package naive
+
+import (
+	"fmt"
+
+	"mibk.dev/phpfmt/token"
+)
+
+// A Node is a node of the syntax tree: a *File, *Block,
+// *Stmt, or *TernaryMiddle. Tokens are not nodes; they are
+// accessed using [Stmt.Nodes] and [TernaryMiddle.Nodes].
+type Node interface {
+	aNode()
+}
+
+func (*File) aNode()          {}
+func (*Block) aNode()         {}
+func (*Stmt) aNode()          {}
+func (*TernaryMiddle) aNode() {}
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses a syntax tree in depth-first order: It starts by
+// calling v.Visit(node); node must not be nil. If the visitor w
+// returned by v.Visit(node) is not nil, Walk is invoked recursively
+// with visitor w for each of the children of node, followed by
+// a call of w.Visit(nil). The children of a statement are its
+// blocks and ternary middle operands, in the source order.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *File:
+		Walk(v, n.block)
+	case *Block:
+		for _, s := range n.nodes {
+			Walk(v, s)
+		}
+	case *Stmt:
+		walkNodes(v, n.nodes)
+	case *TernaryMiddle:
+		walkNodes(v, n.nodes)
+	default:
+		panic(fmt.Sprintf("naive.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkNodes(v Visitor, nodes []any) {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *Block:
+			Walk(v, n)
+		case *TernaryMiddle:
+			Walk(v, n)
+		case token.Token:
+			// Not a node.
+		default:
+			panic(fmt.Sprintf("naive.Walk: unexpected node type %T", n))
+		}
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a syntax tree in depth-first order: It starts
+// by calling f(node); node must not be nil. If f returns true,
+// Inspect invokes f recursively for each of the children of node,
+// followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,63 @@
+package naive_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"mibk.dev/phpfmt/naive"
+	"mibk.dev/phpfmt/phpver"
+	"mibk.dev/phpfmt/token"
+)
+
+func TestInspect(t *testing.T) {
+	const src = `<?php
+function f(int $a, &$b)
+{
+	return $a ? -$b : (int) $c;
+}
+`
+	f, err := naive.Parse(strings.NewReader(src), phpver.Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := func(nodes []any) string {
+		var s string
+		for _, x := range nodes {
+			switch tok, _ := x.(token.Token); tok.Type {
+			case 0, token.Whitespace:
+			case naive.CastToken:
+				s += fmt.Sprintf(" cast%s@%v", tok.Text, tok.Pos)
+			default:
+				s += fmt.Sprintf(" %v@%v", tok.Type, tok.Pos)
+			}
+		}
+		return s
+	}
+	var got []string
+	naive.Inspect(f, func(n naive.Node) bool {
+		switch n := n.(type) {
+		case *naive.Block:
+			got = append(got, fmt.Sprintf("block %v %v%v %v-%v multiline=%v",
+				n.Kind(), n.Open(), n.Close(), n.OpenPos(), n.ClosePos(), n.Multiline()))
+		case *naive.Stmt:
+			got = append(got, fmt.Sprintf("stmt %v:%s", n.Kind(), tokens(n.Nodes())))
+		case *naive.TernaryMiddle:
+			got = append(got, "ternary:"+tokens(n.Nodes()))
+		}
+		return true
+	})
+	want := []string{
+		"block Illegal <?phpEOF 1:1-6:1 multiline=true",
+		"stmt function: function@2:1 Ident@2:10",
+		"block function () 2:11-2:23 multiline=false",
+		"stmt function: Ident@2:12 Var@2:16 ,@2:18",
+		"stmt function: &@2:20 Var@2:21",
+		"block function {} 3:1-5:1 multiline=true",
+		"stmt Illegal: return@4:2 Var@4:9 cast(int)@4:20 Var@4:26 ;@4:28",
+		"ternary: -@4:14 Var@4:15",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,262 @@
+package naive
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"mibk.dev/phpfmt/token"
+)
+
+// tabWidth is the width of an indentation level
+// when measuring lines for Config.MaxWidth.
+const tabWidth = 4
+
+// A stmtSpan records the tokens printed for a statement
+// in a multiline block.
+type stmtSpan struct {
+	stmt       *Stmt
+	start, end int // indices to printer.tokens
+}
+
+// A wrapper breaks the statements whose lines are too long.
+// It works in rounds: after each printing of the file, it measures
+// the lines, and for each statement with overlong lines, it tries
+// the next way of breaking it: the chain of its lowest-precedence
+// operators, and its outermost ( and [ blocks, widest first. A change
+// that does not shorten the statement's lines is reverted.
+//
+// The lines of the statements in multiline blocks are measured
+// separately, so the statements are broken from the outside in.
+type wrapper struct {
+	maxWidth int
+	states   map[*Stmt]*wrapState
+}
+
+type wrapState struct {
+	changes  []func() (undo func())
+	next     int    // index to changes
+	undo     func() // undoes the last change; nil if settled
+	overflow int    // before the last change
+}
+
+// wrap measures the lines printed by p, and changes the statements
+// with overlong lines. It reports whether any statement was changed,
+// so the file needs to be printed again.
+func (w *wrapper) wrap(p *printer) bool {
+	overflow := w.measure(p)
+	changed := false
+	for _, sp := range p.spans {
+		s := sp.stmt
+		over := overflow[s]
+		st := w.states[s]
+		if st == nil {
+			if over == 0 {
+				continue
+			}
+			st = &wrapState{changes: p.lineBreaks(s)}
+			w.states[s] = st
+		}
+		if st.undo != nil {
+			if over >= st.overflow {
+				// It did not help.
+				st.undo()
+				changed = true
+				over = st.overflow
+			}
+			st.undo = nil
+		}
+		if over > 0 && st.next < len(st.changes) {
+			st.undo = st.changes[st.next]()
+			st.next++
+			st.overflow = over
+			changed = true
+		}
+	}
+	return changed
+}
+
+// measure returns by how many columns the lines printed for each
+// statement exceed the maximum width in total. A line belongs to the
+// innermost statement that contains its first token.
+func (w *wrapper) measure(p *printer) map[*Stmt]int {
+	owner := make([]*Stmt, len(p.tokens))
+	for _, sp := range p.spans { // inner statements come first
+		for i := sp.start; i < min(sp.end, len(owner)); i++ {
+			if owner[i] == nil {
+				owner[i] = sp.stmt
+			}
+		}
+	}
+
+	overflow := make(map[*Stmt]int)
+	eachLine(p.tokens, func(first, width int) {
+		if width > w.maxWidth && owner[first] != nil {
+			overflow[owner[first]] += width - w.maxWidth
+		}
+	})
+	return overflow
+}
+
+// eachLine calls f with the index of the first token
+// and the width of each line printed as tokens.
+func eachLine(tokens []any, f func(first, width int)) {
+	width, first := 0, -1
+	justIndented := false
+	endLine := func() {
+		if first >= 0 {
+			f(first, width)
+		}
+		width, first = 0, -1
+	}
+	for i, tok := range tokens {
+		switch tok := tok.(type) {
+		case token.Token:
+			justIndented = false
+			if first < 0 {
+				first = i
+			}
+			lines := strings.Split(strings.ReplaceAll(tok.Text, "\r", ""), "\n")
+			for j, l := range lines {
+				if j > 0 {
+					endLine()
+					first = i
+				}
+				width += utf8.RuneCountInString(l)
+			}
+		case indentation:
+			justIndented = true
+			width += int(tok) * tabWidth
+		case whitespace:
+			switch {
+			case tok == newline:
+				endLine()
+			case !justIndented:
+				width++
+			}
+		}
+	}
+	endLine()
+}
+
+// lineBreaks returns the changes that break s into more lines,
+// in the order they should be tried.
+func (p *printer) lineBreaks(s *Stmt) []func() (undo func()) {
+	var changes []func() (undo func())
+	if prec, ok := p.chainPrec(s); ok {
+		changes = append(changes, func() func() { return breakOps(s, p.opsOf(s, prec)) })
+	}
+	var blocks []*Block
+	for _, n := range s.nodes {
+		if b, ok := n.(*Block); ok && !b.multiline && len(b.nodes) > 0 && b.kind != token.For &&
+			(b.open == token.Lparen || b.open == token.Lbrack) {
+			blocks = append(blocks, b)
+		}
+	}
+	slices.SortStableFunc(blocks, func(a, b *Block) int {
+		return textLen([]any{b}) - textLen([]any{a})
+	})
+	for _, b := range blocks {
+		changes = append(changes, func() func() { return explode(b) })
+	}
+	return changes
+}
+
+// chainPrec returns the precedence of the lowest-precedence binary
+// operators in s, outside of its blocks. It reports false if there
+// are no such operators.
+func (p *printer) chainPrec(s *Stmt) (prec int, ok bool) {
+	for i, n := range s.nodes {
+		tok, isTok := n.(token.Token)
+		if !isTok || i == 0 {
+			continue
+		}
+		if pr, isOp := p.prec(tok.Type); isOp && pr > opPrec[token.Not] && pr >= prec {
+			prec, ok = pr, true
+		}
+	}
+	return prec, ok
+}
+
+// opsOf returns the indices of the operators of precedence prec in s.
+func (p *printer) opsOf(s *Stmt, prec int) []int {
+	var ops []int
+	for i, n := range s.nodes {
+		tok, isTok := n.(token.Token)
+		if !isTok || i == 0 {
+			continue
+		}
+		if pr, isOp := p.prec(tok.Type); isOp && pr == prec {
+			ops = append(ops, i)
+		}
+	}
+	return ops
+}
+
+// breakOps starts a new line before each of the operators
+// at the indices ops of s.nodes.
+func breakOps(s *Stmt, ops []int) (undo func()) {
+	saved := s.nodes
+	var nodes []any
+	last := 0
+	for _, i := range ops {
+		nodes = append(nodes, saved[last:i]...)
+		last = i
+		if ws, ok := nodes[len(nodes)-1].(token.Token); ok && ws.Type == token.Whitespace {
+			if strings.Contains(ws.Text, "\n") {
+				continue
+			}
+			nodes = nodes[:len(nodes)-1]
+		}
+		nodes = append(nodes, token.Token{Type: token.Whitespace, Text: "\n"})
+	}
+	s.nodes = append(nodes, saved[last:]...)
+	return func() { s.nodes = saved }
+}
+
+// explode turns b into a multiline block, with each of its
+// statements on a separate line.
+func explode(b *Block) (undo func()) {
+	saved := *b
+	stmts := make([][]any, len(b.nodes))
+	for i, s := range b.nodes {
+		stmts[i] = s.nodes
+		if i == 0 {
+			continue
+		}
+		nodes := []any{token.Token{Type: token.Whitespace, Text: "\n"}}
+		if tok, ok := s.nodes[0].(token.Token); ok && tok.Type == token.Whitespace {
+			nodes = append(nodes, s.nodes[1:]...)
+		} else {
+			nodes = append(nodes, s.nodes...)
+		}
+		s.nodes = nodes
+	}
+	b.multiline = true
+	b.indented = true
+	return func() {
+		*b = saved
+		for i, s := range b.nodes {
+			s.nodes = stmts[i]
+		}
+	}
+}
+
+// textLen returns the length of the text of the tokens in nodes.
+func textLen(nodes []any) int {
+	n := 0
+	for _, x := range nodes {
+		switch x := x.(type) {
+		case token.Token:
+			n += len(x.Text)
+		case *Block:
+			n += 2
+			for _, s := range x.nodes {
+				n += textLen(s.nodes)
+			}
+		case *TernaryMiddle:
+			n += textLen(x.nodes)
+		}
+	}
+	return n
+}
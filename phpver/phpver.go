@@ -0,0 +1,61 @@
+// Package phpver defines PHP language versions.
+package phpver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Version is a PHP version encoded the same way as PHP_VERSION_ID,
+// e.g. PHP 8.1.2 is 80102. The zero Version stands for the latest
+// version supported.
+type Version int
+
+// PHP versions that changed the syntax in a way that matters
+// for formatting.
+const (
+	PHP54 Version = 50400
+	PHP73 Version = 70300 // trailing commas in calls
+	PHP74 Version = 70400
+	PHP80 Version = 80000 // attributes; trailing commas in parameter lists; concat precedence
+	PHP85 Version = 80500
+
+	Latest = PHP85
+)
+
+// Parse parses a version such as 8.1 or 7.4.33.
+func Parse(s string) (Version, error) {
+	var v Version
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid PHP version %q", s)
+	}
+	for i, mul := range []Version{10000, 100, 1} {
+		if i >= len(parts) {
+			break
+		}
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 || i > 0 && n > 99 {
+			return 0, fmt.Errorf("invalid PHP version %q", s)
+		}
+		v += Version(n) * mul
+	}
+	return v, nil
+}
+
+// AtLeast reports whether v is min or later.
+func (v Version) AtLeast(min Version) bool {
+	return v == 0 || v >= min
+}
+
+func (v Version) String() string {
+	if v == 0 {
+		return "latest"
+	}
+	s := fmt.Sprintf("%d.%d", v/10000, v/100%100)
+	if patch := v % 100; patch > 0 {
+		s += fmt.Sprintf(".%d", patch)
+	}
+	return s
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"unicode/utf8"
+
+	"mibk.dev/phpfmt/format"
+)
+
+// reportFormats lists the formats of -report.
+var reportFormats = map[string]func(io.Writer, []fileReport) error{
+	"checkstyle": writeCheckstyle,
+	"junit":      writeJUnit,
+	"sarif":      writeSARIF,
+	"json":       writeJSONReport,
+}
+
+// A fileReport describes the problems found in a single file.
+// A file without problems is formatted.
+type fileReport struct {
+	Path     string    `json:"file"`
+	Problems []problem `json:"problems"`
+}
+
+// A problem is an unformatted file, a syntax error, or another
+// problem found in a file.
+type problem struct {
+	problemPos
+	Severity string `json:"severity"` // error or warning
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+// A problemPos is the position of a problem. Line and Column are
+// 1-based; they are zero if the position is unknown. Columns are
+// counted in runes (Unicode code points) in all the formats, as
+// are the columns of errors reported by package format.
+type problemPos struct {
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+}
+
+// sourcePos returns the position of p in src.
+func sourcePos(src []byte, p format.Position) problemPos {
+	prefix := src[p.Offset-(p.Column-1) : p.Offset]
+	return problemPos{p.Line, utf8.RuneCount(prefix) + 1}
+}
+
+// Rules of the problems.
+var rules = []struct{ id, desc string }{
+	{"unformatted", "The file is not formatted."},
+	{"syntax", "The file cannot be parsed."},
+	{"verify", "The formatted code is not equivalent to the source code."},
+	{"doc-comment", "A doc comment cannot be parsed, so it is left unformatted."},
+	{"file", "The file cannot be processed."},
+}
+
+// newFileReport describes the result of the file job j.
+func newFileReport(j *fileJob) fileReport {
+	r := fileReport{Path: filepath.ToSlash(j.path), Problems: []problem{}}
+	var fe *format.Error
+	switch {
+	case errors.As(j.err, &fe) && errors.Is(fe.Err, format.ErrNotEquivalent):
+		r.Problems = append(r.Problems, problem{problemPos{fe.Line, fe.Column}, "error", "verify", fe.Err.Error()})
+	case errors.As(j.err, &fe):
+		r.Problems = append(r.Problems, problem{problemPos{fe.Line, fe.Column}, "error", "syntax", fe.Err.Error()})
+	case j.err != nil:
+		r.Problems = append(r.Problems, problem{Severity: "error", Rule: "file", Message: j.err.Error()})
+	case j.changed:
+		msg := "file is not formatted"
+		if *inPlace {
+			msg = "file was not formatted"
+		}
+		r.Problems = append(r.Problems, problem{j.firstDiff, "error", "unformatted", msg})
+	}
+	for _, w := range j.warns {
+		r.Problems = append(r.Problems, problem{problemPos{w.Line, w.Column}, "warning", "doc-comment", w.Err.Error()})
+	}
+	return r
+}
+
+func writeJSONReport(w io.Writer, files []fileReport) error {
+	if files == nil {
+		files = []fileReport{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(files)
+}
+
+func writeXML(w io.Writer, v any) error {
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func writeCheckstyle(w io.Writer, files []fileReport) error {
+	type xmlError struct {
+		Line     int    `xml:"line,attr"`
+		Column   int    `xml:"column,attr,omitempty"`
+		Severity string `xml:"severity,attr"`
+		Message  string `xml:"message,attr"`
+		Source   string `xml:"source,attr"`
+	}
+	type xmlFile struct {
+		Name   string     `xml:"name,attr"`
+		Errors []xmlError `xml:"error"`
+	}
+	type checkstyle struct {
+		XMLName xml.Name  `xml:"checkstyle"`
+		Version string    `xml:"version,attr"`
+		Files   []xmlFile `xml:"file"`
+	}
+	cs := checkstyle{Version: "4.3"}
+	for _, f := range files {
+		xf := xmlFile{Name: f.Path}
+		for _, p := range f.Problems {
+			xf.Errors = append(xf.Errors, xmlError{p.Line, p.Column, p.Severity, p.Message, "phpfmt." + p.Rule})
+		}
+		cs.Files = append(cs.Files, xf)
+	}
+	return writeXML(w, cs)
+}
+
+func writeJUnit(w io.Writer, files []fileReport) error {
+	type xmlResult struct {
+		Message string `xml:"message,attr"`
+		Type    string `xml:"type,attr"`
+		Text    string `xml:",chardata"`
+	}
+	type xmlTestCase struct {
+		Name      string     `xml:"name,attr"`
+		ClassName string     `xml:"classname,attr"`
+		Failure   *xmlResult `xml:"failure"`
+		Error     *xmlResult `xml:"error"`
+		SystemOut string     `xml:"system-out,omitempty"`
+	}
+	type xmlTestSuite struct {
+		Name      string        `xml:"name,attr"`
+		Tests     int           `xml:"tests,attr"`
+		Failures  int           `xml:"failures,attr"`
+		Errors    int           `xml:"errors,attr"`
+		TestCases []xmlTestCase `xml:"testcase"`
+	}
+	type testSuites struct {
+		XMLName xml.Name       `xml:"testsuites"`
+		Suites  []xmlTestSuite `xml:"testsuite"`
+	}
+	ts := xmlTestSuite{Name: "phpfmt", Tests: len(files)}
+	for _, f := range files {
+		tc := xmlTestCase{Name: f.Path, ClassName: "phpfmt"}
+		for _, p := range f.Problems {
+			text := fmt.Sprintf("%s: %s", position(f.Path, p), p.Message)
+			switch {
+			case p.Severity == "warning":
+				tc.SystemOut += text + "\n"
+			case p.Rule == "unformatted":
+				tc.Failure = &xmlResult{p.Message, p.Rule, text}
+				ts.Failures++
+			default:
+				tc.Error = &xmlResult{p.Message, p.Rule, text}
+				ts.Errors++
+			}
+		}
+		ts.TestCases = append(ts.TestCases, tc)
+	}
+	return writeXML(w, testSuites{Suites: []xmlTestSuite{ts}})
+}
+
+// position formats the position of p in the file path.
+func position(path string, p problem) string {
+	switch {
+	case p.Line == 0:
+		return path
+	case p.Column == 0:
+		return fmt.Sprintf("%s:%d", path, p.Line)
+	}
+	return fmt.Sprintf("%s:%d:%d", path, p.Line, p.Column)
+}
+
+func writeSARIF(w io.Writer, files []fileReport) error {
+	type region struct {
+		StartLine   int `json:"startLine"`
+		StartColumn int `json:"startColumn,omitempty"`
+	}
+	type physicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *region `json:"region,omitempty"`
+	}
+	type location struct {
+		PhysicalLocation physicalLocation `json:"physicalLocation"`
+	}
+	type message struct {
+		Text string `json:"text"`
+	}
+	type result struct {
+		RuleID    string     `json:"ruleId"`
+		Level     string     `json:"level"`
+		Message   message    `json:"message"`
+		Locations []location `json:"locations"`
+	}
+	type rule struct {
+		ID               string  `json:"id"`
+		ShortDescription message `json:"shortDescription"`
+	}
+	type driver struct {
+		Name  string `json:"name"`
+		Rules []rule `json:"rules"`
+	}
+	type run struct {
+		Tool struct {
+			Driver driver `json:"driver"`
+		} `json:"tool"`
+		ColumnKind string   `json:"columnKind"`
+		Results    []result `json:"results"`
+	}
+	type sarifLog struct {
+		Schema  string `json:"$schema"`
+		Version string `json:"version"`
+		Runs    []run  `json:"runs"`
+	}
+
+	var driverRules []rule
+	for _, r := range rules {
+		driverRules = append(driverRules, rule{r.id, message{r.desc}})
+	}
+	results := []result{}
+	for _, f := range files {
+		for _, p := range f.Problems {
+			var loc location
+			loc.PhysicalLocation.ArtifactLocation.URI = f.Path
+			if p.Line > 0 {
+				loc.PhysicalLocation.Region = &region{p.Line, p.Column}
+			}
+			results = append(results, result{p.Rule, p.Severity, message{p.Message}, []location{loc}})
+		}
+	}
+	r := run{ColumnKind: "unicodeCodePoints", Results: results}
+	r.Tool.Driver = driver{"phpfmt", driverRules}
+	log := sarifLog{"https://json.schemastore.org/sarif-2.1.0.json", "2.1.0", []run{r}}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(log)
+}
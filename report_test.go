@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"mibk.dev/phpfmt/format"
+)
+
+var testReports = []fileReport{
+	{Path: "a.php", Problems: []problem{}},
+	{Path: "b.php", Problems: []problem{
+		{problemPos{3, 7}, "error", "syntax", "unexpected )"},
+		{problemPos{10, 4}, "warning", "doc-comment", `expecting "*/"`},
+	}},
+	{Path: "c.php", Problems: []problem{
+		{problemPos{2, 0}, "error", "unformatted", "file is not formatted"},
+	}},
+}
+
+func TestCheckstyleReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeCheckstyle(&buf, testReports); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<checkstyle version="4.3">
+	<file name="a.php"></file>
+	<file name="b.php">
+		<error line="3" column="7" severity="error" message="unexpected )" source="phpfmt.syntax"></error>
+		<error line="10" column="4" severity="warning" message="expecting &#34;*/&#34;" source="phpfmt.doc-comment"></error>
+	</file>
+	<file name="c.php">
+		<error line="2" severity="error" message="file is not formatted" source="phpfmt.unformatted"></error>
+	</file>
+</checkstyle>
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestJUnitReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeJUnit(&buf, testReports); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+	<testsuite name="phpfmt" tests="3" failures="1" errors="1">
+		<testcase name="a.php" classname="phpfmt"></testcase>
+		<testcase name="b.php" classname="phpfmt">
+			<error message="unexpected )" type="syntax">b.php:3:7: unexpected )</error>
+			<system-out>b.php:10:4: expecting &#34;*/&#34;&#xA;</system-out>
+		</testcase>
+		<testcase name="c.php" classname="phpfmt">
+			<failure message="file is not formatted" type="unformatted">c.php:2: file is not formatted</failure>
+		</testcase>
+	</testsuite>
+</testsuites>
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSARIFReport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeSARIF(&buf, testReports[1:2]); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+	"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+	"version": "2.1.0",
+	"runs": [
+		{
+			"tool": {
+				"driver": {
+					"name": "phpfmt",
+					"rules": [
+						{
+							"id": "unformatted",
+							"shortDescription": {
+								"text": "The file is not formatted."
+							}
+						},
+						{
+							"id": "syntax",
+							"shortDescription": {
+								"text": "The file cannot be parsed."
+							}
+						},
+						{
+							"id": "verify",
+							"shortDescription": {
+								"text": "The formatted code is not equivalent to the source code."
+							}
+						},
+						{
+							"id": "doc-comment",
+							"shortDescription": {
+								"text": "A doc comment cannot be parsed, so it is left unformatted."
+							}
+						},
+						{
+							"id": "file",
+							"shortDescription": {
+								"text": "The file cannot be processed."
+							}
+						}
+					]
+				}
+			},
+			"columnKind": "unicodeCodePoints",
+			"results": [
+				{
+					"ruleId": "syntax",
+					"level": "error",
+					"message": {
+						"text": "unexpected )"
+					},
+					"locations": [
+						{
+							"physicalLocation": {
+								"artifactLocation": {
+									"uri": "b.php"
+								},
+								"region": {
+									"startLine": 3,
+									"startColumn": 7
+								}
+							}
+						}
+					]
+				},
+				{
+					"ruleId": "doc-comment",
+					"level": "warning",
+					"message": {
+						"text": "expecting \"*/\""
+					},
+					"locations": [
+						{
+							"physicalLocation": {
+								"artifactLocation": {
+									"uri": "b.php"
+								},
+								"region": {
+									"startLine": 10,
+									"startColumn": 4
+								}
+							}
+						}
+					]
+				}
+			]
+		}
+	]
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestNewFileReport(t *testing.T) {
+	notEquiv := fmt.Errorf("%w: got x", format.ErrNotEquivalent)
+	tests := []struct {
+		name string
+		job  *fileJob
+		want []problem
+	}{
+		{"formatted", &fileJob{path: "a.php"}, []problem{}},
+		{
+			"unformatted",
+			&fileJob{path: "a.php", changed: true, firstDiff: problemPos{2, 5}},
+			[]problem{{problemPos{2, 5}, "error", "unformatted", "file is not formatted"}},
+		},
+		{
+			"syntax",
+			&fileJob{path: "a.php", err: &format.Error{Filename: "a.php", Line: 3, Column: 7, Err: errors.New("unexpected )")}},
+			[]problem{{problemPos{3, 7}, "error", "syntax", "unexpected )"}},
+		},
+		{
+			"verify",
+			&fileJob{path: "a.php", err: &format.Error{Filename: "a.php", Line: 1, Column: 2, Err: notEquiv}},
+			[]problem{{problemPos{1, 2}, "error", "verify", notEquiv.Error()}},
+		},
+		{
+			"file",
+			&fileJob{path: "a.php", err: errors.New("permission denied")},
+			[]problem{{Severity: "error", Rule: "file", Message: "permission denied"}},
+		},
+		{
+			"warnings",
+			&fileJob{path: "a.php", changed: true, firstDiff: problemPos{1, 1}, warns: format.Warnings{
+				{Filename: "a.php", Line: 4, Column: 18, Err: errors.New("bad type")},
+			}},
+			[]problem{
+				{problemPos{1, 1}, "error", "unformatted", "file is not formatted"},
+				{problemPos{4, 18}, "warning", "doc-comment", "bad type"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		r := newFileReport(tt.job)
+		if r.Path != "a.php" {
+			t.Errorf("%s: got path %q, want a.php", tt.name, r.Path)
+		}
+		if !reflect.DeepEqual(r.Problems, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, r.Problems, tt.want)
+		}
+	}
+}
+
+func TestSourcePos(t *testing.T) {
+	src := []byte("<?php\n$a = 'ž😀';  \n")
+	edits := format.Edits(src, []byte("<?php\n$a = 'ž😀';\n"))
+	if got, want := sourcePos(src, edits[0].Start), (problemPos{2, 11}); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,740 @@
+package syntax
+
+import "mibk.dev/phpfmt/token"
+
+// A Node is a node of the syntax tree.
+type Node interface {
+	Pos() token.Pos // position of the first character
+	End() token.Pos // position of the character after the node
+	aNode()
+}
+
+type node struct {
+	pos, end token.Pos
+}
+
+func (n *node) Pos() token.Pos { return n.pos }
+func (n *node) End() token.Pos { return n.end }
+func (*node) aNode()           {}
+
+// A Comment is a single // #, /* */, or /** */ comment.
+type Comment struct {
+	node
+	Text string
+}
+
+// Commented nodes have comments attached.
+type commented struct {
+	Doc      []*Comment // comments on the lines before the node
+	Trailing *Comment   // comment after the node on the same line, or nil
+}
+
+// ----------------------------------------------------------------------------
+// Files
+
+// A File is a PHP source file.
+type File struct {
+	node
+	Stmts []Stmt
+	End_  []*Comment // comments after the last statement
+}
+
+// ----------------------------------------------------------------------------
+// Names and attributes
+
+// A Name is a (possibly qualified) name of a class, function,
+// or constant, e.g. Foo, Foo\Bar, or \Foo\Bar. The names relative
+// to the current namespace (namespace\Foo) start with "namespace".
+type Name struct {
+	node
+	Parts  []string
+	Global bool // fully qualified, i.e. starting with \
+}
+
+// An Ident is an identifier, e.g. the name of a declaration
+// or a member.
+type Ident struct {
+	expr
+	Name string
+}
+
+// An AttrGroup is a group of attributes, #[A, B(1)].
+type AttrGroup struct {
+	node
+	Attrs []*Attr
+}
+
+// An Attr is a single attribute.
+type Attr struct {
+	node
+	Name *Name
+	Args *ArgList // or nil
+}
+
+// ----------------------------------------------------------------------------
+// Types
+
+// A Type is a type declaration of a parameter, a property,
+// a return value, or a class constant.
+type Type interface {
+	Node
+	aType()
+}
+
+type typ struct{ node }
+
+func (*typ) aType() {}
+
+// A NamedType is a single type, e.g. int, self, or Foo\Bar.
+type NamedType struct {
+	typ
+	Name *Name
+}
+
+// A NullableType is a type preceded by ?.
+type NullableType struct {
+	typ
+	Type Type
+}
+
+// A UnionType is a union of types, A|B.
+type UnionType struct {
+	typ
+	Types []Type
+}
+
+// An IntersectionType is an intersection of types, A&B.
+type IntersectionType struct {
+	typ
+	Types []Type
+}
+
+// A ParenType is a parenthesized intersection type
+// in a disjunctive normal form type, e.g. (A&B)|null.
+type ParenType struct {
+	typ
+	Type Type
+}
+
+// ----------------------------------------------------------------------------
+// Expressions
+
+// An Expr is an expression.
+type Expr interface {
+	Node
+	aExpr()
+}
+
+type expr struct{ node }
+
+func (*expr) aExpr() {}
+
+// A BadExpr is a placeholder for an expression
+// that could not be parsed.
+type BadExpr struct{ expr }
+
+// A Var is a variable, $name.
+type Var struct {
+	expr
+	Name string // without $
+}
+
+// A VarVar is a variable variable, $$x or ${expr}.
+type VarVar struct {
+	expr
+	X     Expr
+	Brace bool // ${expr}
+}
+
+// A NameExpr is a name used as an expression, i.e. a constant,
+// or the name of a function or class, e.g. in calls.
+type NameExpr struct {
+	expr
+	Name *Name
+}
+
+// A Lit is an integer, float, or string literal (including heredocs
+// and shell commands in backticks), as written in the source code.
+type Lit struct {
+	expr
+	Kind  token.Type // token.Int, token.Float, or token.String
+	Value string
+}
+
+// ArrayKind is the syntax of an ArrayLit.
+type ArrayKind int
+
+const (
+	ShortArray ArrayKind = iota // [...]
+	LongArray                   // array(...)
+	ListArray                   // list(...)
+)
+
+// An ArrayLit is an array literal, or the destructuring
+// of an array on the left side of an assignment.
+type ArrayLit struct {
+	expr
+	Kind      ArrayKind
+	Items     []*ArrayItem // nil items are skipped, as in [, $b] = $x
+	Multiline bool
+}
+
+// An ArrayItem is an item of an ArrayLit.
+type ArrayItem struct {
+	node
+	commented
+	Key    Expr // or nil
+	Value  Expr
+	ByRef  bool // &$value
+	Unpack bool // ...$value
+}
+
+// A ParenExpr is a parenthesized expression.
+type ParenExpr struct {
+	expr
+	X Expr
+}
+
+// A UnaryExpr is an expression with a prefix operator:
+// !, ~, -, +, or @ (Op is token.At), or the reference
+// operator & in some contexts (Op is token.BitAnd).
+type UnaryExpr struct {
+	expr
+	Op token.Type
+	X  Expr
+}
+
+// An IncDecExpr is an increment or decrement, e.g. $i++ or --$i.
+type IncDecExpr struct {
+	expr
+	Op   token.Type // token.Inc or token.Dec
+	X    Expr
+	Post bool
+}
+
+// A BinaryExpr is an expression with a binary operator,
+// including the logical and, or, and xor.
+type BinaryExpr struct {
+	expr
+	Op   token.Type
+	X, Y Expr
+}
+
+// An AssignExpr is an assignment, e.g. $a = $b, $a .= $b,
+// or $a = &$b.
+type AssignExpr struct {
+	expr
+	Op    token.Type // token.Assign, token.AddAssign, …
+	X, Y  Expr
+	ByRef bool
+}
+
+// A TernaryExpr is a conditional expression, a ? b : c,
+// or a ?: c if Then is nil.
+type TernaryExpr struct {
+	expr
+	Cond, Then, Else Expr
+}
+
+// A CastExpr is a type cast, e.g. (int) $x.
+type CastExpr struct {
+	expr
+	Type string // as written, e.g. "int"
+	X    Expr
+}
+
+// An InstanceofExpr is an instanceof expression.
+type InstanceofExpr struct {
+	expr
+	X     Expr
+	Class Expr
+}
+
+// An ArgList is a list of arguments in parentheses.
+type ArgList struct {
+	node
+	Args      []*Arg
+	Callable  bool // first-class callable syntax, f(...)
+	Multiline bool
+}
+
+// An Arg is an argument of a call.
+type Arg struct {
+	node
+	commented
+	Name   *Ident // of a named argument, or nil
+	Value  Expr
+	Unpack bool // ...$value
+}
+
+// A CallExpr is a call of a function, or a method
+// (if Fun is a *PropExpr or a *StaticExpr).
+type CallExpr struct {
+	expr
+	Fun  Expr
+	Args *ArgList
+}
+
+// A PropExpr is an access of an object property, $x->name.
+type PropExpr struct {
+	expr
+	X        Expr
+	Name     Expr // *Ident, *Var, *VarVar, or *BraceExpr
+	NullSafe bool // ?->
+}
+
+// A StaticExpr is an access of a static member of a class,
+// e.g. A::B, A::$b, or A::class.
+type StaticExpr struct {
+	expr
+	Class Expr
+	Name  Expr // *Ident, *Var, *VarVar, or *BraceExpr
+}
+
+// A BraceExpr is an expression in braces used as a member
+// name, e.g. in $x->{$name}.
+type BraceExpr struct {
+	expr
+	X Expr
+}
+
+// An IndexExpr is an array access, $x[index], or $x[] if Index is nil.
+type IndexExpr struct {
+	expr
+	X     Expr
+	Index Expr
+}
+
+// A NewExpr is an instantiation of a class.
+type NewExpr struct {
+	expr
+	Class Node     // *NameExpr, another Expr, or *ClassDecl if anonymous
+	Args  *ArgList // or nil if there are no parentheses
+}
+
+// A CloneExpr is a clone expression.
+type CloneExpr struct {
+	expr
+	X Expr
+}
+
+// A PrintExpr is a print expression.
+type PrintExpr struct {
+	expr
+	X Expr
+}
+
+// A ThrowExpr is a throw expression.
+type ThrowExpr struct {
+	expr
+	X Expr
+}
+
+// A YieldExpr is a yield expression, yield, yield $value,
+// or yield $key => $value.
+type YieldExpr struct {
+	expr
+	Key, Value Expr // or nil
+}
+
+// A YieldFromExpr is a yield from expression.
+type YieldFromExpr struct {
+	expr
+	X Expr
+}
+
+// An IncludeExpr is an include, include_once, require,
+// or require_once expression.
+type IncludeExpr struct {
+	expr
+	Kind string // as written
+	X    Expr
+}
+
+// A ClosureExpr is an anonymous function.
+type ClosureExpr struct {
+	expr
+	Attrs  []*AttrGroup
+	Static bool
+	ByRef  bool
+	Params *ParamList
+	Uses   []*ClosureUse
+	Result Type // or nil
+	Body   *BlockStmt
+}
+
+// A ClosureUse is a variable used by a closure.
+type ClosureUse struct {
+	node
+	Var   *Var
+	ByRef bool
+}
+
+// An ArrowFuncExpr is an arrow function, fn($x) => $x.
+type ArrowFuncExpr struct {
+	expr
+	Attrs  []*AttrGroup
+	Static bool
+	ByRef  bool
+	Params *ParamList
+	Result Type // or nil
+	Body   Expr
+}
+
+// A MatchExpr is a match expression.
+type MatchExpr struct {
+	expr
+	Subject Expr
+	Arms    []*MatchArm
+}
+
+// A MatchArm is an arm of a match expression.
+type MatchArm struct {
+	node
+	commented
+	Conds []Expr // nil for the default arm
+	Body  Expr
+}
+
+// ----------------------------------------------------------------------------
+// Statements
+
+// A Stmt is a statement, including declarations.
+type Stmt interface {
+	Node
+	aStmt()
+}
+
+type stmt struct {
+	node
+	commented
+}
+
+func (*stmt) aStmt() {}
+
+// An ExprStmt is an expression used as a statement.
+type ExprStmt struct {
+	stmt
+	X Expr
+}
+
+// An EchoStmt is an echo statement.
+type EchoStmt struct {
+	stmt
+	Exprs []Expr
+}
+
+// An InlineHTML is text outside of PHP tags.
+type InlineHTML struct {
+	stmt
+	Close bool // preceded by a closing tag, ?>
+	Text  string
+	Open  bool // followed by an opening tag, <?php
+}
+
+// A BlockStmt is a list of statements in braces, or the body
+// of a control structure written in the alternative syntax.
+type BlockStmt struct {
+	stmt
+	Stmts []Stmt
+	End_  []*Comment // comments after the last statement
+}
+
+// An EmptyStmt is a lone semicolon.
+type EmptyStmt struct{ stmt }
+
+// An IfStmt is an if statement.
+type IfStmt struct {
+	stmt
+	Cond   Expr
+	Then   Stmt
+	Else   Stmt // *IfStmt, another statement, or nil
+	ElseIf bool // Else is an *IfStmt written as elseif
+	Alt    bool // if (…): … endif;
+}
+
+// A WhileStmt is a while loop.
+type WhileStmt struct {
+	stmt
+	Cond Expr
+	Body Stmt
+	Alt  bool // while (…): … endwhile;
+}
+
+// A DoWhileStmt is a do-while loop.
+type DoWhileStmt struct {
+	stmt
+	Body Stmt
+	Cond Expr
+}
+
+// A ForStmt is a for loop.
+type ForStmt struct {
+	stmt
+	Init, Cond, Post []Expr
+	Body             Stmt
+	Alt              bool // for (…): … endfor;
+}
+
+// A ForeachStmt is a foreach loop.
+type ForeachStmt struct {
+	stmt
+	X     Expr
+	Key   Expr // or nil
+	Value Expr
+	ByRef bool
+	Body  Stmt
+	Alt   bool // foreach (…): … endforeach;
+}
+
+// A SwitchStmt is a switch statement.
+type SwitchStmt struct {
+	stmt
+	Tag   Expr
+	Cases []*CaseClause
+	End_  []*Comment // comments after the last case
+	Alt   bool       // switch (…): … endswitch;
+}
+
+// A CaseClause is a case or the default of a switch statement.
+type CaseClause struct {
+	node
+	commented
+	Expr Expr // nil for default
+	Body []Stmt
+}
+
+// A BranchStmt is a break or continue statement.
+type BranchStmt struct {
+	stmt
+	Tok   token.Type // token.Break or token.Continue
+	Depth Expr       // or nil
+}
+
+// A ReturnStmt is a return statement.
+type ReturnStmt struct {
+	stmt
+	X Expr // or nil
+}
+
+// A GlobalStmt is a global statement.
+type GlobalStmt struct {
+	stmt
+	Vars []Expr
+}
+
+// A StaticStmt declares static variables.
+type StaticStmt struct {
+	stmt
+	Vars []*StaticVar
+}
+
+// A StaticVar is a variable declared in a StaticStmt.
+type StaticVar struct {
+	node
+	Var     *Var
+	Default Expr // or nil
+}
+
+// An UnsetStmt is an unset statement.
+type UnsetStmt struct {
+	stmt
+	Exprs []Expr
+}
+
+// A GotoStmt is a goto statement.
+type GotoStmt struct {
+	stmt
+	Label *Ident
+}
+
+// A LabelStmt is a label for goto.
+type LabelStmt struct {
+	stmt
+	Label *Ident
+}
+
+// A TryStmt is a try statement.
+type TryStmt struct {
+	stmt
+	Body    *BlockStmt
+	Catches []*CatchClause
+	Finally *BlockStmt // or nil
+}
+
+// A CatchClause is a catch of a try statement.
+type CatchClause struct {
+	node
+	Types []*Name
+	Var   *Var // or nil
+	Body  *BlockStmt
+}
+
+// A DeclareStmt is a declare statement.
+type DeclareStmt struct {
+	stmt
+	Directives []*ConstItem
+	Body       Stmt // or nil
+	Alt        bool // declare(…): … enddeclare;
+}
+
+// A NamespaceStmt is a namespace declaration.
+type NamespaceStmt struct {
+	stmt
+	Name *Name      // or nil for the global namespace
+	Body *BlockStmt // or nil
+}
+
+// A UseStmt imports names.
+type UseStmt struct {
+	stmt
+	Kind   string // "", "function", or "const"
+	Prefix *Name  // of a group use, or nil
+	Items  []*UseItem
+}
+
+// A UseItem is a name imported by a UseStmt.
+type UseItem struct {
+	node
+	Kind  string // in a group use; "", "function", or "const"
+	Name  *Name
+	Alias *Ident // or nil
+}
+
+// A ConstStmt declares constants outside of classes.
+type ConstStmt struct {
+	stmt
+	Items []*ConstItem
+}
+
+// A ConstItem is a constant in a ConstStmt or a ClassConstDecl,
+// or a directive of a DeclareStmt.
+type ConstItem struct {
+	node
+	Name  *Ident
+	Value Expr
+}
+
+// A FuncDecl declares a function.
+type FuncDecl struct {
+	stmt
+	Attrs  []*AttrGroup
+	ByRef  bool
+	Name   *Ident
+	Params *ParamList
+	Result Type // or nil
+	Body   *BlockStmt
+}
+
+// A ParamList is a list of parameters in parentheses.
+type ParamList struct {
+	node
+	Params    []*Param
+	Multiline bool
+}
+
+// A Param is a parameter of a function.
+type Param struct {
+	node
+	commented
+	Attrs     []*AttrGroup
+	Modifiers []string // of promoted constructor parameters
+	Type      Type     // or nil
+	ByRef     bool
+	Variadic  bool
+	Var       *Var
+	Default   Expr        // or nil
+	Hooks     []*PropHook // or nil
+}
+
+// A ClassDecl declares a class, an interface, a trait, or an enum.
+// It is also used for anonymous classes in a NewExpr.
+type ClassDecl struct {
+	stmt
+	Attrs      []*AttrGroup
+	Modifiers  []string   // e.g. abstract, final, readonly
+	Kind       token.Type // token.Class, token.Interface, token.Trait, or token.Enum
+	Name       *Ident     // or nil if anonymous
+	Args       *ArgList   // of an anonymous class, or nil
+	EnumType   Type       // or nil
+	Extends    []*Name
+	Implements []*Name
+	Members    []Stmt
+	End_       []*Comment // comments after the last member
+}
+
+// A MethodDecl declares a method.
+type MethodDecl struct {
+	stmt
+	Attrs     []*AttrGroup
+	Modifiers []string
+	ByRef     bool
+	Name      *Ident
+	Params    *ParamList
+	Result    Type       // or nil
+	Body      *BlockStmt // or nil if abstract
+}
+
+// A PropertyDecl declares properties.
+type PropertyDecl struct {
+	stmt
+	Attrs     []*AttrGroup
+	Modifiers []string // e.g. public, private(set), or var
+	Type      Type     // or nil
+	Props     []*PropItem
+	Hooks     []*PropHook // or nil
+}
+
+// A PropItem is a property declared in a PropertyDecl.
+type PropItem struct {
+	node
+	Var     *Var
+	Default Expr // or nil
+}
+
+// A PropHook is a get or set hook of a property.
+type PropHook struct {
+	node
+	Attrs     []*AttrGroup
+	Modifiers []string
+	ByRef     bool
+	Name      *Ident
+	Params    *ParamList // or nil
+	Body      Node       // *BlockStmt, an Expr (after =>), or nil
+}
+
+// A ClassConstDecl declares class constants.
+type ClassConstDecl struct {
+	stmt
+	Attrs     []*AttrGroup
+	Modifiers []string
+	Type      Type // or nil
+	Items     []*ConstItem
+}
+
+// An EnumCase declares a case of an enum.
+type EnumCase struct {
+	stmt
+	Attrs []*AttrGroup
+	Name  *Ident
+	Value Expr // or nil
+}
+
+// A TraitUse uses traits in a class.
+type TraitUse struct {
+	stmt
+	Traits []*Name
+	Rules  []*TraitRule // or nil if there are no braces
+}
+
+// A TraitRule is a rule of a TraitUse, either
+// Trait::method insteadof A, B, or
+// [Trait::]method as [modifier] [alias].
+type TraitRule struct {
+	node
+	Trait     *Name // or nil
+	Method    *Ident
+	Insteadof []*Name
+	Modifier  string // of an as rule, or ""
+	Alias     *Ident // or nil
+}
@@ -0,0 +1,405 @@
+package syntax
+
+import (
+	"strings"
+
+	"mibk.dev/phpfmt/token"
+)
+
+// parseAttrs parses groups of attributes, #[…].
+func (p *parser) parseAttrs() []*AttrGroup {
+	var groups []*AttrGroup
+	for p.tok.Type == token.Hash {
+		g := new(AttrGroup)
+		g.pos = p.tok.Pos
+		p.next()
+		p.expect(token.Lbrack)
+		for p.tok.Type != token.Rbrack && p.tok.Type != token.EOF {
+			a := new(Attr)
+			a.pos = p.tok.Pos
+			a.Name = p.parseName()
+			if p.tok.Type == token.Lparen {
+				a.Args = p.parseArgs()
+			}
+			a.end = p.lastEnd
+			g.Attrs = append(g.Attrs, a)
+			if !p.got(token.Comma) {
+				break
+			}
+		}
+		p.expect(token.Rbrack)
+		g.end = p.lastEnd
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// parseAttributed parses a statement preceded by attributes.
+func (p *parser) parseAttributed() Stmt {
+	pos := p.tok.Pos
+	attrs := p.parseAttrs()
+	switch p.tok.Type {
+	case token.Function:
+		if p.peek(1) == token.Ident || p.peek(1) == token.BitAnd && p.peek(2) == token.Ident {
+			f := p.parseFuncDecl(attrs)
+			f.pos = pos
+			return f
+		}
+	case token.Abstract, token.Final, token.Readonly, token.Class, token.Interface, token.Trait, token.Enum:
+		c := p.parseClassDecl(attrs)
+		c.pos = pos
+		return c
+	}
+	// A closure or an arrow function.
+	x := p.parseClosure(attrs)
+	setPos(x, pos, p.lastEnd)
+	x = p.parseBinaryRest(pos, p.parsePostfix(pos, x), precLowest)
+	s := &ExprStmt{X: x}
+	p.semi()
+	s.pos, s.end = pos, p.lastEnd
+	return s
+}
+
+func (p *parser) parseFuncDecl(attrs []*AttrGroup) *FuncDecl {
+	f := &FuncDecl{Attrs: attrs}
+	f.pos = p.tok.Pos
+	p.expect(token.Function)
+	f.ByRef = p.got(token.BitAnd)
+	f.Name = p.parseIdent()
+	f.Params = p.parseParams()
+	if p.got(token.Colon) {
+		f.Result = p.parseType()
+	}
+	f.Body = p.parseBlockStmt()
+	f.end = p.lastEnd
+	return f
+}
+
+// parseParams parses a list of parameters in parentheses.
+func (p *parser) parseParams() *ParamList {
+	l := new(ParamList)
+	l.pos = p.tok.Pos
+	p.expect(token.Lparen)
+	l.Multiline = p.tok.Pos.Line > l.pos.Line
+	for p.tok.Type != token.Rparen && p.tok.Type != token.EOF {
+		doc := p.takeComments()
+		x := new(Param)
+		x.pos = p.tok.Pos
+		x.Attrs = p.parseAttrs()
+		x.Modifiers = p.parseModifiers()
+		if !p.at(token.Var, token.BitAnd, token.Ellipsis) {
+			x.Type = p.parseType()
+		}
+		x.ByRef = p.got(token.BitAnd)
+		x.Variadic = p.got(token.Ellipsis)
+		x.Var = p.parseVar()
+		if p.got(token.Assign) {
+			x.Default = p.parseExpr()
+		}
+		if p.tok.Type == token.Lbrace {
+			x.Hooks = p.parseHooks()
+		}
+		x.end = p.lastEnd
+		l.Params = append(l.Params, x)
+		more := p.got(token.Comma)
+		p.attach(x, doc)
+		if !more {
+			break
+		}
+	}
+	p.expect(token.Rparen)
+	l.end = p.lastEnd
+	return l
+}
+
+// parseModifiers parses modifiers of class members,
+// including asymmetric visibility, e.g. private(set).
+func (p *parser) parseModifiers() []string {
+	var mods []string
+	for {
+		switch p.tok.Type {
+		case token.Public, token.Protected, token.Private:
+			mod := p.tok.Text
+			p.next()
+			if p.tok.Type == token.Lparen && p.peek(1) == token.Ident && p.peek(2) == token.Rparen {
+				p.next()
+				mod += "(" + p.tok.Text + ")"
+				p.next()
+				p.next()
+			}
+			mods = append(mods, mod)
+		case token.Static, token.Abstract, token.Final, token.Readonly:
+			if p.tok.Type == token.Static && p.peek(1) == token.DoubleColon {
+				// A type, static::class.
+				return mods
+			}
+			mods = append(mods, p.tok.Text)
+			p.next()
+		case token.Ident:
+			if !strings.EqualFold(p.tok.Text, "var") || p.peek(1) == token.Backslash {
+				return mods
+			}
+			mods = append(mods, p.tok.Text)
+			p.next()
+		default:
+			return mods
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Types
+
+func (p *parser) parseType() Type {
+	pos := p.tok.Pos
+	if p.got(token.Qmark) {
+		t := &NullableType{Type: p.parseTypeAtom()}
+		t.pos, t.end = pos, p.lastEnd
+		return t
+	}
+	t := p.parseTypeAtom()
+	switch {
+	case p.tok.Type == token.BitOr:
+		u := &UnionType{Types: []Type{t}}
+		for p.got(token.BitOr) {
+			u.Types = append(u.Types, p.parseTypeAtom())
+		}
+		u.pos, u.end = pos, p.lastEnd
+		return u
+	case p.isIntersection():
+		in := &IntersectionType{Types: []Type{t}}
+		for p.isIntersection() {
+			p.next()
+			in.Types = append(in.Types, p.parseTypeAtom())
+		}
+		in.pos, in.end = pos, p.lastEnd
+		return in
+	}
+	return t
+}
+
+// isIntersection reports whether the current token is the & of
+// an intersection type, rather than of a parameter passed by reference.
+func (p *parser) isIntersection() bool {
+	if p.tok.Type != token.BitAnd {
+		return false
+	}
+	switch typ := p.peek(1); typ {
+	case token.Var, token.Ellipsis, token.BitAnd:
+		return false
+	default:
+		return typ == token.Backslash || typ == token.Lparen || isIdent(typ)
+	}
+}
+
+func (p *parser) parseTypeAtom() Type {
+	pos := p.tok.Pos
+	if p.got(token.Lparen) {
+		t := &ParenType{Type: p.parseType()}
+		p.expect(token.Rparen)
+		t.pos, t.end = pos, p.lastEnd
+		return t
+	}
+	t := &NamedType{Name: p.parseName()}
+	t.pos, t.end = pos, p.lastEnd
+	return t
+}
+
+// ----------------------------------------------------------------------------
+// Classes
+
+// parseClassDecl parses a declaration of a class, an interface,
+// a trait, or an enum, or an anonymous class after new.
+func (p *parser) parseClassDecl(attrs []*AttrGroup) *ClassDecl {
+	c := &ClassDecl{Attrs: attrs}
+	c.pos = p.tok.Pos
+	c.Modifiers = p.parseModifiers()
+	switch p.tok.Type {
+	case token.Class, token.Interface, token.Trait, token.Enum:
+		c.Kind = p.tok.Type
+		p.next()
+	default:
+		p.errorf("expecting class, found %v", p.tok.Token)
+		return c
+	}
+	if c.Kind == token.Class && p.at(token.Lparen, token.Lbrace, token.Extends, token.Implements) {
+		// An anonymous class.
+		if p.tok.Type == token.Lparen {
+			c.Args = p.parseArgs()
+		}
+	} else {
+		c.Name = p.parseIdent()
+	}
+	if c.Kind == token.Enum && p.got(token.Colon) {
+		c.EnumType = p.parseType()
+	}
+	if p.got(token.Extends) {
+		c.Extends = p.parseNameList()
+	}
+	if p.got(token.Implements) {
+		c.Implements = p.parseNameList()
+	}
+	p.expect(token.Lbrace)
+	for p.tok.Type != token.Rbrace && p.tok.Type != token.EOF {
+		doc := p.takeComments()
+		m := p.parseMember()
+		if m == nil {
+			break
+		}
+		p.attach(m.(commentedNode), doc)
+		c.Members = append(c.Members, m)
+	}
+	c.End_ = p.takeComments()
+	p.expect(token.Rbrace)
+	c.end = p.lastEnd
+	return c
+}
+
+func (p *parser) parseNameList() []*Name {
+	list := []*Name{p.parseName()}
+	for p.got(token.Comma) {
+		list = append(list, p.parseName())
+	}
+	return list
+}
+
+func (p *parser) parseMember() Stmt {
+	pos := p.tok.Pos
+	attrs := p.parseAttrs()
+	mods := p.parseModifiers()
+	var m Stmt
+	switch p.tok.Type {
+	case token.Use:
+		if attrs != nil || mods != nil {
+			p.errorf("unexpected %v", p.tok.Token)
+			return nil
+		}
+		m = p.parseTraitUse()
+	case token.Case:
+		p.next()
+		e := &EnumCase{Attrs: attrs, Name: p.parseIdent()}
+		if p.got(token.Assign) {
+			e.Value = p.parseExpr()
+		}
+		p.semi()
+		m = e
+	case token.Const:
+		p.next()
+		c := &ClassConstDecl{Attrs: attrs, Modifiers: mods}
+		if p.peek(1) != token.Assign {
+			c.Type = p.parseType()
+		}
+		c.Items = p.parseConstItems()
+		p.semi()
+		m = c
+	case token.Function:
+		p.next()
+		f := &MethodDecl{Attrs: attrs, Modifiers: mods}
+		f.ByRef = p.got(token.BitAnd)
+		f.Name = p.parseIdent()
+		f.Params = p.parseParams()
+		if p.got(token.Colon) {
+			f.Result = p.parseType()
+		}
+		if p.tok.Type == token.Lbrace {
+			f.Body = p.parseBlockStmt()
+		} else {
+			p.semi()
+		}
+		m = f
+	default:
+		prop := &PropertyDecl{Attrs: attrs, Modifiers: mods}
+		if p.tok.Type != token.Var {
+			prop.Type = p.parseType()
+		}
+		for {
+			it := new(PropItem)
+			it.pos = p.tok.Pos
+			it.Var = p.parseVar()
+			if p.got(token.Assign) {
+				it.Default = p.parseExpr()
+			}
+			it.end = p.lastEnd
+			prop.Props = append(prop.Props, it)
+			if !p.got(token.Comma) {
+				break
+			}
+		}
+		if p.tok.Type == token.Lbrace {
+			prop.Hooks = p.parseHooks()
+		} else {
+			p.semi()
+		}
+		m = prop
+	}
+	setPos(m, pos, p.lastEnd)
+	return m
+}
+
+func (p *parser) parseTraitUse() *TraitUse {
+	u := new(TraitUse)
+	p.expect(token.Use)
+	u.Traits = p.parseNameList()
+	if !p.got(token.Lbrace) {
+		p.semi()
+		return u
+	}
+	u.Rules = []*TraitRule{}
+	for p.tok.Type != token.Rbrace && p.tok.Type != token.EOF {
+		r := new(TraitRule)
+		r.pos = p.tok.Pos
+		if p.peek(1) == token.DoubleColon || p.peek(1) == token.Backslash {
+			r.Trait = p.parseName()
+			p.expect(token.DoubleColon)
+		}
+		r.Method = p.parseIdent()
+		if p.got(token.Insteadof) {
+			r.Insteadof = p.parseNameList()
+		} else {
+			p.expect(token.As)
+			switch p.tok.Type {
+			case token.Public, token.Protected, token.Private, token.Final, token.Static, token.Abstract:
+				r.Modifier = p.tok.Text
+				p.next()
+			}
+			if p.tok.Type != token.Semicolon {
+				r.Alias = p.parseIdent()
+			}
+		}
+		p.expect(token.Semicolon)
+		r.end = p.lastEnd
+		u.Rules = append(u.Rules, r)
+	}
+	p.expect(token.Rbrace)
+	return u
+}
+
+// parseHooks parses the hooks of a property in braces.
+func (p *parser) parseHooks() []*PropHook {
+	p.expect(token.Lbrace)
+	hooks := []*PropHook{}
+	for p.tok.Type != token.Rbrace && p.tok.Type != token.EOF {
+		h := new(PropHook)
+		h.pos = p.tok.Pos
+		h.Attrs = p.parseAttrs()
+		h.Modifiers = p.parseModifiers()
+		h.ByRef = p.got(token.BitAnd)
+		h.Name = p.parseIdent()
+		if p.tok.Type == token.Lparen {
+			h.Params = p.parseParams()
+		}
+		switch {
+		case p.got(token.DoubleArrow):
+			h.Body = p.parseExpr()
+			p.expect(token.Semicolon)
+		case p.tok.Type == token.Lbrace:
+			h.Body = p.parseBlockStmt()
+		default:
+			p.expect(token.Semicolon)
+		}
+		h.end = p.lastEnd
+		hooks = append(hooks, h)
+	}
+	p.expect(token.Rbrace)
+	return hooks
+}
@@ -0,0 +1,27 @@
+// Package syntax implements parsing and printing of PHP source code
+// as a typed syntax tree.
+//
+// Unlike package naive, which models only blocks and statements and
+// formats the code at the token level, the tree built by [Parse] has
+// nodes for expressions, statements, declarations, types, and
+// attributes, with their positions and the comments attached to them.
+// It is meant as a basis for analyses of PHP code, and for rules of
+// formatting that need to know the structure of the code.
+//
+// Comments are attached to the nearest statement, class member,
+// parameter, argument, array item, match arm, or switch case: comments
+// on the lines before such a node are in its Doc field, and a comment
+// following it on the same line is its Trailing comment. Comments in
+// other places, e.g. inside an expression, are added to the Doc of the
+// enclosing node, so [Fprint] may move them.
+//
+// String literals, including heredocs and shell commands in backticks,
+// are kept as they are written, without parsing the interpolated
+// variables. The short echo tag (<?=) is not recognized as an opening
+// tag, so the code up to the closing tag is kept as inline HTML.
+//
+// The package is experimental. Neither package format nor the phpfmt
+// command uses it yet, and the style of [Fprint] does not match theirs
+// in every respect: e.g., it neither spaces binary operators by their
+// precedence, nor aligns columns.
+package syntax
@@ -0,0 +1,612 @@
+package syntax
+
+import (
+	"strings"
+
+	"mibk.dev/phpfmt/phpver"
+	"mibk.dev/phpfmt/token"
+)
+
+// Precedence of operators; higher binds tighter.
+// See https://www.php.net/manual/en/language.operators.precedence.php
+const (
+	precLowest      = 1
+	precAssign      = 4
+	precTernary     = 5
+	precCoalesce    = 6
+	precNot         = 19
+	precInstanceof  = 20
+	precUnary       = 21
+	precPow         = 22
+	precConcatPHP80 = 15
+)
+
+var binaryPrec = map[token.Type]int{
+	token.LowPrecOr:  1,
+	token.LowPrecXor: 2,
+	token.LowPrecAnd: 3,
+	// 4: assignment
+	// 5: ternary
+	token.Coalesce:     precCoalesce,
+	token.Or:           7,
+	token.And:          8,
+	token.BitOr:        9,
+	token.BitXor:       10,
+	token.BitAnd:       11,
+	token.Eq:           12,
+	token.Neq:          12,
+	token.Identical:    12,
+	token.NotIdentical: 12,
+	token.Spaceship:    12,
+	token.Lt:           13,
+	token.Gt:           13,
+	token.Leq:          13,
+	token.Geq:          13,
+	token.Pipe:         14,
+	token.Concat:       precConcatPHP80,
+	token.BitShl:       16,
+	token.BitShr:       16,
+	token.Add:          17,
+	token.Sub:          17,
+	token.Mul:          18,
+	token.Quo:          18,
+	token.Rem:          18,
+	token.Instanceof:   precInstanceof,
+	token.Pow:          precPow,
+}
+
+func isAssignOp(typ token.Type) bool {
+	return typ == token.Assign || token.AddAssign <= typ && typ <= token.CoalesceAssign
+}
+
+// prec returns the precedence of the binary operator typ,
+// or 0 if typ is not a binary operator.
+func (p *parser) prec(typ token.Type) int {
+	if typ == token.Concat && !p.ver.AtLeast(phpver.PHP80) {
+		return binaryPrec[token.Add]
+	}
+	return binaryPrec[typ]
+}
+
+func (p *parser) parseExpr() Expr {
+	return p.parseBinary(precLowest)
+}
+
+func (p *parser) parseExprList() []Expr {
+	list := []Expr{p.parseExpr()}
+	for p.got(token.Comma) {
+		list = append(list, p.parseExpr())
+	}
+	return list
+}
+
+// parseParenExpr parses an expression in parentheses,
+// e.g. the condition of an if statement.
+func (p *parser) parseParenExpr() Expr {
+	p.expect(token.Lparen)
+	x := p.parseExpr()
+	p.expect(token.Rparen)
+	return x
+}
+
+// parseBinary parses an expression whose binary operators
+// have precedence of at least prec.
+func (p *parser) parseBinary(prec int) Expr {
+	pos := p.tok.Pos
+	return p.parseBinaryRest(pos, p.parseUnary(), prec)
+}
+
+// parseBinaryRest parses the rest of a binary expression
+// starting at pos, whose first operand x is already parsed.
+func (p *parser) parseBinaryRest(pos token.Pos, x Expr, prec int) Expr {
+	for {
+		op := p.tok.Type
+		switch {
+		case isAssignOp(op) && isVariable(x):
+			// Assignments bind to variables regardless
+			// of the precedence, e.g. in !$a = f().
+			p.next()
+			a := &AssignExpr{Op: op, X: x}
+			if op == token.Assign && p.got(token.BitAnd) {
+				a.ByRef = true
+			}
+			a.Y = p.parseBinary(precAssign)
+			x = a
+		case op == token.Qmark && prec <= precTernary:
+			p.next()
+			t := &TernaryExpr{Cond: x}
+			if !p.got(token.Colon) {
+				t.Then = p.parseBinary(precAssign)
+				p.expect(token.Colon)
+			}
+			t.Else = p.parseBinary(precTernary + 1)
+			x = t
+		case op == token.Instanceof && prec <= precInstanceof:
+			p.next()
+			x = &InstanceofExpr{X: x, Class: p.parseBinary(precInstanceof + 1)}
+		default:
+			opPrec := p.prec(op)
+			if opPrec == 0 || opPrec < prec {
+				return x
+			}
+			p.next()
+			b := &BinaryExpr{Op: op, X: x}
+			switch op {
+			case token.Coalesce, token.Pow:
+				// Right-associative.
+				b.Y = p.parseBinary(opPrec)
+			default:
+				b.Y = p.parseBinary(opPrec + 1)
+			}
+			x = b
+		}
+		setPos(x, pos, p.lastEnd)
+	}
+}
+
+// isVariable reports whether x can be assigned to.
+func isVariable(x Expr) bool {
+	switch x.(type) {
+	case *Var, *VarVar, *IndexExpr, *PropExpr, *StaticExpr, *ArrayLit:
+		return true
+	}
+	return false
+}
+
+var castTypes = map[string]bool{
+	"int": true, "integer": true,
+	"bool": true, "boolean": true,
+	"float": true, "double": true, "real": true,
+	"string": true, "binary": true,
+	"array": true, "object": true, "unset": true,
+}
+
+func (p *parser) isCast() bool {
+	return p.tok.Type == token.Lparen && p.peek(1) == token.Ident && p.peek(2) == token.Rparen &&
+		castTypes[strings.ToLower(p.items[p.i+1].Text)]
+}
+
+// parseUnary parses an expression with prefix operators,
+// or a primary expression with postfix operators.
+func (p *parser) parseUnary() Expr {
+	pos := p.tok.Pos
+	var x Expr
+	switch typ := p.tok.Type; {
+	case typ == token.Not:
+		p.next()
+		x = &UnaryExpr{Op: typ, X: p.parseBinary(precNot + 1)}
+	case typ == token.Sub, typ == token.Add, typ == token.BitNot, typ == token.At:
+		p.next()
+		x = &UnaryExpr{Op: typ, X: p.parseBinary(precUnary + 1)}
+	case typ == token.Inc, typ == token.Dec:
+		p.next()
+		x = &IncDecExpr{Op: typ, X: p.parseUnary()}
+	case p.isCast():
+		p.next()
+		c := &CastExpr{Type: p.tok.Text}
+		p.next()
+		p.next()
+		c.X = p.parseBinary(precUnary + 1)
+		x = c
+	case typ == token.New:
+		n := p.parseNew()
+		n.pos, n.end = pos, p.lastEnd
+		if _, anon := n.Class.(*ClassDecl); n.Args == nil && !anon {
+			// Members of objects created without
+			// parentheses cannot be accessed.
+			return n
+		}
+		return p.parsePostfix(pos, n)
+	case typ == token.Clone:
+		p.next()
+		x = &CloneExpr{X: p.parseBinary(precUnary + 1)}
+	case typ == token.Print:
+		p.next()
+		x = &PrintExpr{X: p.parseBinary(precAssign)}
+	case typ == token.Throw:
+		p.next()
+		x = &ThrowExpr{X: p.parseExpr()}
+	case typ == token.Yield:
+		p.next()
+		if p.got(token.From) {
+			x = &YieldFromExpr{X: p.parseBinary(precAssign)}
+			break
+		}
+		y := new(YieldExpr)
+		if !p.at(token.Semicolon, token.Rparen, token.Rbrack, token.Comma, token.CloseTag) {
+			y.Value = p.parseBinary(precAssign)
+			if p.got(token.DoubleArrow) {
+				y.Key, y.Value = y.Value, p.parseBinary(precAssign)
+			}
+		}
+		x = y
+	case typ == token.Ident && isInclude(p.tok.Text) && p.peek(1) != token.Backslash:
+		kind := p.tok.Text
+		p.next()
+		x = &IncludeExpr{Kind: kind, X: p.parseExpr()}
+	case typ == token.Function, typ == token.Fn:
+		x = p.parseClosure(nil)
+	case typ == token.Static && (p.peek(1) == token.Function || p.peek(1) == token.Fn):
+		x = p.parseClosure(nil)
+	case typ == token.Hash:
+		attrs := p.parseAttrs()
+		x = p.parseClosure(attrs)
+	default:
+		return p.parsePostfix(pos, p.parsePrimary())
+	}
+	setPos(x, pos, p.lastEnd)
+	return x
+}
+
+func isInclude(name string) bool {
+	switch strings.ToLower(name) {
+	case "include", "include_once", "require", "require_once":
+		return true
+	}
+	return false
+}
+
+// parsePrimary parses an operand of an expression.
+func (p *parser) parsePrimary() Expr {
+	pos := p.tok.Pos
+	var x Expr
+	switch typ := p.tok.Type; typ {
+	case token.Var:
+		x = &Var{Name: p.tok.Text[1:]}
+		p.next()
+	case token.Dollar:
+		x = p.parseVarVar()
+	case token.Int, token.Float, token.String:
+		x = &Lit{Kind: typ, Value: p.tok.Text}
+		p.next()
+	case token.Lbrack:
+		x = p.parseArray(ShortArray, token.Lbrack, token.Rbrack)
+	case token.Lparen:
+		p.next()
+		x = &ParenExpr{X: p.parseExpr()}
+		p.expect(token.Rparen)
+	case token.Match:
+		if p.peek(1) != token.Lparen {
+			x = &NameExpr{Name: p.parseName()}
+			break
+		}
+		x = p.parseMatch()
+	case token.Static:
+		n := new(Name)
+		n.pos = p.tok.Pos
+		n.Parts = []string{p.tok.Text}
+		p.next()
+		n.end = p.lastEnd
+		x = &NameExpr{Name: n}
+	case token.Ident, token.Backslash, token.ReservedConst:
+		if typ == token.Ident && p.peek(1) == token.Lparen {
+			switch strings.ToLower(p.tok.Text) {
+			case "array":
+				p.next()
+				x = p.parseArray(LongArray, token.Lparen, token.Rparen)
+			case "list":
+				p.next()
+				x = p.parseArray(ListArray, token.Lparen, token.Rparen)
+			}
+			if x != nil {
+				break
+			}
+		}
+		x = &NameExpr{Name: p.parseName()}
+	default:
+		if typ.IsKeyword() {
+			switch p.peek(1) {
+			case token.Lparen, token.DoubleColon, token.Backslash:
+				// A soft keyword used as a name, e.g. enum_exists
+				// is not a keyword, but from() may be a function.
+				x = &NameExpr{Name: p.parseName()}
+			}
+		}
+		if x == nil {
+			p.errorf("unexpected %v", p.tok.Token)
+			return &BadExpr{}
+		}
+	}
+	setPos(x, pos, p.lastEnd)
+	return x
+}
+
+func (p *parser) parseVarVar() Expr {
+	v := new(VarVar)
+	v.pos = p.tok.Pos
+	p.expect(token.Dollar)
+	switch p.tok.Type {
+	case token.Lbrace:
+		p.next()
+		v.X = p.parseExpr()
+		v.Brace = true
+		p.expect(token.Rbrace)
+	case token.Dollar:
+		v.X = p.parseVarVar()
+	default:
+		v.X = p.parseVar()
+	}
+	v.end = p.lastEnd
+	return v
+}
+
+// parsePostfix parses the member accesses, calls, and other postfix
+// operators applied to x.
+func (p *parser) parsePostfix(pos token.Pos, x Expr) Expr {
+	for {
+		var y Expr
+		switch p.tok.Type {
+		case token.Lbrack:
+			p.next()
+			ix := &IndexExpr{X: x}
+			if p.tok.Type != token.Rbrack {
+				ix.Index = p.parseExpr()
+			}
+			p.expect(token.Rbrack)
+			y = ix
+		case token.Arrow, token.QmarkArrow:
+			nullSafe := p.tok.Type == token.QmarkArrow
+			p.next()
+			y = &PropExpr{X: x, Name: p.parseMemberName(), NullSafe: nullSafe}
+		case token.DoubleColon:
+			p.next()
+			y = &StaticExpr{Class: x, Name: p.parseMemberName()}
+		case token.Lparen:
+			y = &CallExpr{Fun: x, Args: p.parseArgs()}
+		case token.Inc, token.Dec:
+			if !isVariable(x) {
+				return x
+			}
+			y = &IncDecExpr{Op: p.tok.Type, X: x, Post: true}
+			p.next()
+		default:
+			return x
+		}
+		setPos(y, pos, p.lastEnd)
+		x = y
+	}
+}
+
+// parseMemberName parses the name after -> or ::.
+func (p *parser) parseMemberName() Expr {
+	switch p.tok.Type {
+	case token.Var:
+		return p.parseVar()
+	case token.Dollar:
+		return p.parseVarVar()
+	case token.Lbrace:
+		b := new(BraceExpr)
+		b.pos = p.tok.Pos
+		p.next()
+		b.X = p.parseExpr()
+		p.expect(token.Rbrace)
+		b.end = p.lastEnd
+		return b
+	}
+	return p.parseIdent()
+}
+
+// parseArgs parses a list of arguments in parentheses.
+func (p *parser) parseArgs() *ArgList {
+	l := new(ArgList)
+	l.pos = p.tok.Pos
+	p.expect(token.Lparen)
+	if p.tok.Type == token.Ellipsis && p.peek(1) == token.Rparen {
+		p.next()
+		p.next()
+		l.Callable = true
+		l.end = p.lastEnd
+		return l
+	}
+	l.Multiline = p.tok.Pos.Line > l.pos.Line
+	for p.tok.Type != token.Rparen && p.tok.Type != token.EOF {
+		doc := p.takeComments()
+		a := new(Arg)
+		a.pos = p.tok.Pos
+		if isIdent(p.tok.Type) && p.peek(1) == token.Colon {
+			a.Name = p.parseIdent()
+			p.next()
+		}
+		a.Unpack = p.got(token.Ellipsis)
+		a.Value = p.parseExpr()
+		a.end = p.lastEnd
+		l.Args = append(l.Args, a)
+		more := p.got(token.Comma)
+		p.attach(a, doc)
+		if !more {
+			break
+		}
+	}
+	p.expect(token.Rparen)
+	l.end = p.lastEnd
+	return l
+}
+
+// parseArray parses the items of an array literal.
+func (p *parser) parseArray(kind ArrayKind, open, close token.Type) *ArrayLit {
+	a := &ArrayLit{Kind: kind}
+	openPos := p.tok.Pos
+	p.expect(open)
+	a.Multiline = p.tok.Pos.Line > openPos.Line
+	for p.tok.Type != close && p.tok.Type != token.EOF {
+		if p.got(token.Comma) {
+			// A skipped item, e.g. [, $b] = $x.
+			a.Items = append(a.Items, nil)
+			continue
+		}
+		doc := p.takeComments()
+		it := new(ArrayItem)
+		it.pos = p.tok.Pos
+		switch {
+		case p.got(token.Ellipsis):
+			it.Unpack = true
+			it.Value = p.parseExpr()
+		case p.got(token.BitAnd):
+			it.ByRef = true
+			it.Value = p.parseExpr()
+		default:
+			it.Value = p.parseExpr()
+			if p.got(token.DoubleArrow) {
+				it.Key = it.Value
+				it.ByRef = p.got(token.BitAnd)
+				it.Value = p.parseExpr()
+			}
+		}
+		it.end = p.lastEnd
+		a.Items = append(a.Items, it)
+		more := p.got(token.Comma)
+		p.attach(it, doc)
+		if !more {
+			break
+		}
+	}
+	p.expect(close)
+	return a
+}
+
+func (p *parser) parseNew() *NewExpr {
+	n := new(NewExpr)
+	p.expect(token.New)
+	switch p.tok.Type {
+	case token.Class, token.Readonly, token.Hash:
+		var attrs []*AttrGroup
+		if p.tok.Type == token.Hash {
+			attrs = p.parseAttrs()
+		}
+		c := p.parseClassDecl(attrs)
+		n.Class = c
+		return n
+	case token.Var, token.Dollar:
+		n.Class = p.parseNewVar()
+	case token.Lparen:
+		pos := p.tok.Pos
+		p.next()
+		x := &ParenExpr{X: p.parseExpr()}
+		p.expect(token.Rparen)
+		x.pos, x.end = pos, p.lastEnd
+		n.Class = x
+	case token.Static:
+		n.Class = p.parsePrimary()
+	default:
+		x := &NameExpr{Name: p.parseName()}
+		x.pos, x.end = x.Name.pos, x.Name.end
+		n.Class = x
+	}
+	if p.tok.Type == token.Lparen {
+		n.Args = p.parseArgs()
+	}
+	return n
+}
+
+// parseNewVar parses a class name given by a variable,
+// e.g. in new $this->class().
+func (p *parser) parseNewVar() Expr {
+	pos := p.tok.Pos
+	var x Expr
+	if p.tok.Type == token.Dollar {
+		x = p.parseVarVar()
+	} else {
+		x = p.parseVar()
+	}
+	for {
+		var y Expr
+		switch p.tok.Type {
+		case token.Lbrack:
+			p.next()
+			ix := &IndexExpr{X: x}
+			if p.tok.Type != token.Rbrack {
+				ix.Index = p.parseExpr()
+			}
+			p.expect(token.Rbrack)
+			y = ix
+		case token.Arrow, token.QmarkArrow:
+			nullSafe := p.tok.Type == token.QmarkArrow
+			p.next()
+			y = &PropExpr{X: x, Name: p.parseMemberName(), NullSafe: nullSafe}
+		case token.DoubleColon:
+			p.next()
+			if p.tok.Type != token.Var && p.tok.Type != token.Dollar {
+				p.errorf("expecting variable, found %v", p.tok.Token)
+				return x
+			}
+			y = &StaticExpr{Class: x, Name: p.parseMemberName()}
+		default:
+			return x
+		}
+		setPos(y, pos, p.lastEnd)
+		x = y
+	}
+}
+
+func (p *parser) parseMatch() *MatchExpr {
+	m := new(MatchExpr)
+	p.expect(token.Match)
+	m.Subject = p.parseParenExpr()
+	p.expect(token.Lbrace)
+	for p.tok.Type != token.Rbrace && p.tok.Type != token.EOF {
+		doc := p.takeComments()
+		arm := new(MatchArm)
+		arm.pos = p.tok.Pos
+		if p.tok.Type == token.Default && p.peek(1) == token.DoubleArrow {
+			p.next()
+		} else {
+			for p.tok.Type != token.DoubleArrow && p.tok.Type != token.EOF {
+				arm.Conds = append(arm.Conds, p.parseExpr())
+				if !p.got(token.Comma) {
+					break
+				}
+			}
+		}
+		p.expect(token.DoubleArrow)
+		arm.Body = p.parseExpr()
+		arm.end = p.lastEnd
+		m.Arms = append(m.Arms, arm)
+		more := p.got(token.Comma)
+		p.attach(arm, doc)
+		if !more {
+			break
+		}
+	}
+	p.expect(token.Rbrace)
+	return m
+}
+
+// parseClosure parses an anonymous or arrow function.
+func (p *parser) parseClosure(attrs []*AttrGroup) Expr {
+	static := p.got(token.Static)
+	if p.got(token.Fn) {
+		f := &ArrowFuncExpr{Attrs: attrs, Static: static}
+		f.ByRef = p.got(token.BitAnd)
+		f.Params = p.parseParams()
+		if p.got(token.Colon) {
+			f.Result = p.parseType()
+		}
+		p.expect(token.DoubleArrow)
+		f.Body = p.parseExpr()
+		return f
+	}
+	f := &ClosureExpr{Attrs: attrs, Static: static}
+	p.expect(token.Function)
+	f.ByRef = p.got(token.BitAnd)
+	f.Params = p.parseParams()
+	if p.got(token.Use) {
+		p.expect(token.Lparen)
+		for p.tok.Type != token.Rparen && p.tok.Type != token.EOF {
+			u := new(ClosureUse)
+			u.pos = p.tok.Pos
+			u.ByRef = p.got(token.BitAnd)
+			u.Var = p.parseVar()
+			u.end = p.lastEnd
+			f.Uses = append(f.Uses, u)
+			if !p.got(token.Comma) {
+				break
+			}
+		}
+		p.expect(token.Rparen)
+	}
+	if p.got(token.Colon) {
+		f.Result = p.parseType()
+	}
+	f.Body = p.parseBlockStmt()
+	return f
+}
@@ -0,0 +1,796 @@
+package syntax
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"mibk.dev/phpfmt/phpver"
+	"mibk.dev/phpfmt/token"
+)
+
+// SyntaxError records an error and the position it occurred on.
+type SyntaxError struct {
+	Line, Column int
+	Err          error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line:%d:%d: %v", e.Line, e.Column, e.Err)
+}
+
+// An item is a significant token with the comments preceding it.
+type item struct {
+	token.Token
+	end      token.Pos
+	comments []*Comment
+}
+
+type parser struct {
+	ver phpver.Version
+
+	items []item
+	i     int // index of tok in items
+	tok   item
+	err   error
+
+	lastEnd token.Pos  // end of the last consumed token
+	pending []*Comment // comments not attached yet
+}
+
+// Parse parses a single PHP file written for the PHP version ver.
+// If an error occurs while parsing (except io errors), the returned
+// error will be of type *SyntaxError.
+func Parse(r io.Reader, ver phpver.Version) (*File, error) {
+	p := &parser{ver: ver}
+	if err := p.scan(r); err != nil {
+		return nil, err
+	}
+	p.tok = p.items[0]
+	p.pending = p.tok.comments
+	file := p.parseFile()
+	if p.err != nil {
+		return nil, p.err
+	}
+	return file, nil
+}
+
+// scan reads all the tokens of the source code, so that
+// the parser can look ahead as far as it needs.
+func (p *parser) scan(r io.Reader) error {
+	scan := token.NewScanner(r, p.ver)
+	var comments []*Comment
+	for {
+		tok := scan.Next()
+		switch tok.Type {
+		case token.Whitespace:
+			continue
+		case token.Comment, token.DocComment:
+			c := &Comment{Text: tok.Text}
+			c.pos, c.end = tok.Pos, endOf(tok)
+			comments = append(comments, c)
+			continue
+		case token.Illegal:
+			return &SyntaxError{tok.Pos.Line, tok.Pos.Column, fmt.Errorf("illegal token %q", tok.Text)}
+		}
+		p.items = append(p.items, item{Token: tok, end: endOf(tok), comments: comments})
+		comments = nil
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+	if err := scan.Err(); err != nil {
+		if se, ok := err.(*token.ScanError); ok {
+			// Make sure we always return *SyntaxError.
+			return &SyntaxError{se.Pos.Line, se.Pos.Column, se.Err}
+		}
+		return fmt.Errorf("scan: %v", err)
+	}
+	return nil
+}
+
+// endOf returns the position after tok.
+func endOf(tok token.Token) token.Pos {
+	pos := tok.Pos
+	if i := strings.LastIndexByte(tok.Text, '\n'); i >= 0 {
+		pos.Line += strings.Count(tok.Text, "\n")
+		pos.Column = 1 + len([]rune(tok.Text[i+1:]))
+	} else {
+		pos.Column += len([]rune(tok.Text))
+	}
+	return pos
+}
+
+func (p *parser) next() {
+	if p.tok.Type == token.EOF {
+		return
+	}
+	p.lastEnd = p.tok.end
+	p.i++
+	p.tok = p.items[p.i]
+	p.pending = append(p.pending, p.tok.comments...)
+}
+
+// peek returns the type of the n-th token after the current one.
+func (p *parser) peek(n int) token.Type {
+	if p.i+n >= len(p.items) {
+		return token.EOF
+	}
+	return p.items[p.i+n].Type
+}
+
+func (p *parser) got(typ token.Type) bool {
+	if p.tok.Type == typ {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(typ token.Type) {
+	if !p.got(typ) {
+		p.errorf("expecting %v, found %v", typ, p.tok.Token)
+	}
+}
+
+func (p *parser) errorf(format string, args ...any) {
+	if p.err == nil {
+		se := &SyntaxError{Err: fmt.Errorf(format, args...)}
+		se.Line, se.Column = p.tok.Pos.Line, p.tok.Pos.Column
+		p.err = se
+		// Stop parsing.
+		p.i = len(p.items) - 1
+		p.tok = p.items[p.i]
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Comments
+
+// A commentedNode is a node that has comments attached.
+type commentedNode interface {
+	Node
+	comments() *commented
+}
+
+func (c *commented) comments() *commented { return c }
+
+// takeComments returns the pending comments.
+func (p *parser) takeComments() []*Comment {
+	c := p.pending
+	p.pending = nil
+	return c
+}
+
+// attach attaches doc to n, along with a trailing comment
+// following n on the same line, if any. The comments inside n
+// that are not attached to its parts are added to its Doc.
+func (p *parser) attach(n commentedNode, doc []*Comment) {
+	c := n.comments()
+	c.Doc = append(doc, c.Doc...)
+	end := n.End()
+	rest := p.pending[:0]
+	for _, com := range p.pending {
+		switch {
+		case posBefore(com.pos, end):
+			c.Doc = append(c.Doc, com)
+		case c.Trailing == nil && com.pos.Line == end.Line:
+			c.Trailing = com
+		default:
+			rest = append(rest, com)
+		}
+	}
+	p.pending = rest
+}
+
+func posBefore(a, b token.Pos) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// ----------------------------------------------------------------------------
+// Files and statements
+
+func (p *parser) parseFile() *File {
+	f := new(File)
+	f.pos = p.tok.Pos
+	p.got(token.OpenTag)
+	for p.tok.Type != token.EOF {
+		f.Stmts = append(f.Stmts, p.parseStmt())
+	}
+	f.End_ = p.takeComments()
+	f.end = p.lastEnd
+	return f
+}
+
+// parseStmtList parses statements until one of the tokens in stop,
+// or a keyword that ends a control structure, e.g. endif.
+func (p *parser) parseStmtList(stop ...token.Type) []Stmt {
+	var list []Stmt
+	for p.tok.Type != token.EOF && !p.at(stop...) && !p.atAltEnd() {
+		list = append(list, p.parseStmt())
+	}
+	return list
+}
+
+func (p *parser) at(types ...token.Type) bool {
+	for _, typ := range types {
+		if p.tok.Type == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBlockStmt parses a block of statements in braces. The comments
+// before the block are left to the statements in it.
+func (p *parser) parseBlockStmt() *BlockStmt {
+	b := new(BlockStmt)
+	b.pos = p.tok.Pos
+	p.expect(token.Lbrace)
+	b.Stmts = p.parseStmtList(token.Rbrace)
+	b.End_ = p.takeComments()
+	p.expect(token.Rbrace)
+	b.end = p.lastEnd
+	return b
+}
+
+// parseBody parses the body of a control structure.
+func (p *parser) parseBody() Stmt {
+	if p.tok.Type == token.Lbrace {
+		return p.parseBlockStmt()
+	}
+	return p.parseStmt()
+}
+
+// parseBodyOrAlt parses the body of a control structure, or, if it
+// is written in the alternative syntax, the body and the keyword end,
+// e.g. endwhile, that follows it.
+func (p *parser) parseBodyOrAlt(end string) (body Stmt, alt bool) {
+	if p.tok.Type != token.Colon {
+		return p.parseBody(), false
+	}
+	body = p.parseAltBody()
+	p.endAlt(end)
+	return body, true
+}
+
+// altEnds are the keywords that end control structures
+// written in the alternative syntax.
+var altEnds = map[string]bool{
+	"endif":      true,
+	"endwhile":   true,
+	"endfor":     true,
+	"endforeach": true,
+	"endswitch":  true,
+	"enddeclare": true,
+}
+
+func (p *parser) atAltEnd() bool {
+	return p.tok.Type == token.Ident && altEnds[strings.ToLower(p.tok.Text)]
+}
+
+// parseAltBody parses the body of a control structure written
+// in the alternative syntax, from the colon until the keyword
+// that ends the structure, or one of the tokens in stop.
+func (p *parser) parseAltBody(stop ...token.Type) *BlockStmt {
+	b := new(BlockStmt)
+	b.pos = p.tok.Pos
+	p.expect(token.Colon)
+	b.Stmts = p.parseStmtList(stop...)
+	b.End_ = p.takeComments()
+	b.end = p.lastEnd
+	return b
+}
+
+// endAlt parses the keyword end, which ends a control structure
+// written in the alternative syntax, and the semicolon after it.
+func (p *parser) endAlt(end string) {
+	if p.tok.Type != token.Ident || !strings.EqualFold(p.tok.Text, end) {
+		p.errorf("expecting %s, found %v", end, p.tok.Token)
+		return
+	}
+	p.next()
+	p.semi()
+}
+
+// semi parses the end of a statement: a semicolon, or a closing
+// tag, which is left to be parsed as an InlineHTML.
+func (p *parser) semi() {
+	if p.tok.Type != token.CloseTag {
+		p.expect(token.Semicolon)
+	}
+}
+
+func (p *parser) parseStmt() Stmt {
+	doc := p.takeComments()
+	s := p.parseSimpleStmt()
+	if s == nil {
+		// An error occurred.
+		s = new(EmptyStmt)
+	}
+	if n, ok := s.(commentedNode); ok {
+		p.attach(n, doc)
+	}
+	return s
+}
+
+func (p *parser) parseSimpleStmt() Stmt {
+	pos := p.tok.Pos
+	var s Stmt
+	switch p.tok.Type {
+	case token.InlineHTML, token.CloseTag:
+		s = p.parseInlineHTML()
+	case token.Lbrace:
+		return p.parseBlockStmt()
+	case token.Semicolon:
+		p.next()
+		s = new(EmptyStmt)
+	case token.If:
+		s = p.parseIf(nil)
+	case token.While:
+		p.next()
+		w := new(WhileStmt)
+		w.Cond = p.parseParenExpr()
+		w.Body, w.Alt = p.parseBodyOrAlt("endwhile")
+		s = w
+	case token.Do:
+		p.next()
+		d := new(DoWhileStmt)
+		d.Body = p.parseBody()
+		p.expect(token.While)
+		d.Cond = p.parseParenExpr()
+		p.semi()
+		s = d
+	case token.For:
+		s = p.parseFor()
+	case token.Foreach:
+		s = p.parseForeach()
+	case token.Switch:
+		s = p.parseSwitch()
+	case token.Break, token.Continue:
+		b := &BranchStmt{Tok: p.tok.Type}
+		p.next()
+		if !p.at(token.Semicolon, token.CloseTag) {
+			b.Depth = p.parseExpr()
+		}
+		p.semi()
+		s = b
+	case token.Return:
+		p.next()
+		r := new(ReturnStmt)
+		if !p.at(token.Semicolon, token.CloseTag) {
+			r.X = p.parseExpr()
+		}
+		p.semi()
+		s = r
+	case token.Echo:
+		p.next()
+		s = &EchoStmt{Exprs: p.parseExprList()}
+		p.semi()
+	case token.Global:
+		p.next()
+		s = &GlobalStmt{Vars: p.parseExprList()}
+		p.semi()
+	case token.Static:
+		if p.peek(1) != token.Var {
+			s = p.parseExprStmt()
+			break
+		}
+		p.next()
+		st := new(StaticStmt)
+		for {
+			v := new(StaticVar)
+			v.pos = p.tok.Pos
+			v.Var = p.parseVar()
+			if p.got(token.Assign) {
+				v.Default = p.parseExpr()
+			}
+			v.end = p.lastEnd
+			st.Vars = append(st.Vars, v)
+			if !p.got(token.Comma) {
+				break
+			}
+		}
+		p.semi()
+		s = st
+	case token.Goto:
+		p.next()
+		s = &GotoStmt{Label: p.parseIdent()}
+		p.semi()
+	case token.Try:
+		s = p.parseTry()
+	case token.Declare:
+		s = p.parseDeclare()
+	case token.Namespace:
+		s = p.parseNamespace()
+	case token.Use:
+		s = p.parseUse()
+	case token.Const:
+		p.next()
+		s = &ConstStmt{Items: p.parseConstItems()}
+		p.semi()
+	case token.Function:
+		if p.peek(1) == token.Ident || p.peek(1) == token.BitAnd && p.peek(2) == token.Ident {
+			s = p.parseFuncDecl(nil)
+			break
+		}
+		s = p.parseExprStmt()
+	case token.Hash:
+		return p.parseAttributed()
+	case token.Abstract, token.Final, token.Class, token.Interface, token.Trait:
+		return p.parseClassDecl(nil)
+	case token.Readonly:
+		if p.peek(1) == token.Lparen {
+			s = p.parseExprStmt()
+			break
+		}
+		return p.parseClassDecl(nil)
+	case token.Enum:
+		if p.peek(1) == token.Ident {
+			return p.parseClassDecl(nil)
+		}
+		s = p.parseExprStmt()
+	case token.Ident:
+		switch {
+		case p.atAltEnd():
+			p.errorf("unexpected %v", p.tok.Token)
+			return nil
+		case p.peek(1) == token.Colon:
+			s = &LabelStmt{Label: p.parseIdent()}
+			p.next()
+		case strings.EqualFold(p.tok.Text, "unset") && p.peek(1) == token.Lparen:
+			p.next()
+			p.next()
+			u := new(UnsetStmt)
+			for p.tok.Type != token.Rparen && p.tok.Type != token.EOF {
+				u.Exprs = append(u.Exprs, p.parseExpr())
+				if !p.got(token.Comma) {
+					break
+				}
+			}
+			p.expect(token.Rparen)
+			p.semi()
+			s = u
+		default:
+			s = p.parseExprStmt()
+		}
+	case token.Else, token.Case, token.Default, token.Catch, token.Finally:
+		p.errorf("unexpected %v", p.tok.Token)
+		return nil
+	default:
+		s = p.parseExprStmt()
+	}
+	setPos(s, pos, p.lastEnd)
+	return s
+}
+
+func (n *node) setPos(pos, end token.Pos) { n.pos, n.end = pos, end }
+
+// setPos sets the position of n, which is a node created by the parser.
+func setPos(n Node, pos, end token.Pos) {
+	n.(interface{ setPos(pos, end token.Pos) }).setPos(pos, end)
+}
+
+func (p *parser) parseExprStmt() *ExprStmt {
+	s := &ExprStmt{X: p.parseExpr()}
+	p.semi()
+	return s
+}
+
+func (p *parser) parseInlineHTML() *InlineHTML {
+	h := new(InlineHTML)
+	h.Close = p.got(token.CloseTag)
+	if p.tok.Type == token.InlineHTML {
+		h.Text = p.tok.Text
+		p.next()
+	}
+	h.Open = p.got(token.OpenTag)
+	return h
+}
+
+// parseIf parses an if statement, or the one in the else branch
+// of outer, if not nil.
+func (p *parser) parseIf(outer *IfStmt) *IfStmt {
+	s := new(IfStmt)
+	s.pos = p.tok.Pos
+	p.expect(token.If)
+	s.Cond = p.parseParenExpr()
+	s.Alt = p.tok.Type == token.Colon
+	if outer != nil && outer.ElseIf && s.Alt != outer.Alt {
+		// The syntaxes cannot be mixed.
+		p.errorf("unexpected %v", p.tok.Token)
+	}
+	if s.Alt {
+		s.Then = p.parseAltBody(token.Else)
+	} else {
+		s.Then = p.parseBody()
+	}
+	if p.tok.Type == token.Else {
+		elsePos := p.tok.Pos
+		p.next()
+		if p.tok.Type == token.If {
+			s.ElseIf = p.tok.Pos.Line == elsePos.Line && p.tok.Pos.Column == elsePos.Column+len("else")
+			if s.Alt && !s.ElseIf {
+				p.errorf("unexpected %v", p.tok.Token)
+			}
+			doc := p.takeComments()
+			elseif := p.parseIf(s)
+			p.attach(elseif, doc)
+			s.Else = elseif
+			s.end = p.lastEnd
+			return s
+		}
+		if s.Alt {
+			s.Else = p.parseAltBody()
+		} else {
+			s.Else = p.parseBody()
+		}
+	}
+	if s.Alt {
+		p.endAlt("endif")
+	}
+	s.end = p.lastEnd
+	return s
+}
+
+func (p *parser) parseFor() *ForStmt {
+	s := new(ForStmt)
+	p.expect(token.For)
+	p.expect(token.Lparen)
+	list := func(end token.Type) []Expr {
+		var x []Expr
+		if p.tok.Type != end {
+			x = p.parseExprList()
+		}
+		p.expect(end)
+		return x
+	}
+	s.Init = list(token.Semicolon)
+	s.Cond = list(token.Semicolon)
+	s.Post = list(token.Rparen)
+	s.Body, s.Alt = p.parseBodyOrAlt("endfor")
+	return s
+}
+
+func (p *parser) parseForeach() *ForeachStmt {
+	s := new(ForeachStmt)
+	p.expect(token.Foreach)
+	p.expect(token.Lparen)
+	s.X = p.parseExpr()
+	p.expect(token.As)
+	byRef := p.got(token.BitAnd)
+	v := p.parseExpr()
+	if p.got(token.DoubleArrow) {
+		s.Key = v
+		byRef = p.got(token.BitAnd)
+		v = p.parseExpr()
+	}
+	s.Value, s.ByRef = v, byRef
+	p.expect(token.Rparen)
+	s.Body, s.Alt = p.parseBodyOrAlt("endforeach")
+	return s
+}
+
+func (p *parser) parseSwitch() *SwitchStmt {
+	s := new(SwitchStmt)
+	p.expect(token.Switch)
+	s.Tag = p.parseParenExpr()
+	if s.Alt = p.got(token.Colon); !s.Alt {
+		p.expect(token.Lbrace)
+	}
+	for p.tok.Type == token.Case || p.tok.Type == token.Default {
+		doc := p.takeComments()
+		c := new(CaseClause)
+		c.pos = p.tok.Pos
+		if p.got(token.Case) {
+			c.Expr = p.parseExpr()
+		} else {
+			p.next()
+		}
+		if !p.got(token.Semicolon) {
+			p.expect(token.Colon)
+		}
+		c.end = p.lastEnd
+		p.attach(c, doc)
+		c.Body = p.parseStmtList(token.Case, token.Default, token.Rbrace)
+		if len(c.Body) > 0 {
+			c.end = c.Body[len(c.Body)-1].End()
+		}
+		s.Cases = append(s.Cases, c)
+	}
+	s.End_ = p.takeComments()
+	if s.Alt {
+		p.endAlt("endswitch")
+	} else {
+		p.expect(token.Rbrace)
+	}
+	return s
+}
+
+func (p *parser) parseTry() *TryStmt {
+	s := new(TryStmt)
+	p.expect(token.Try)
+	s.Body = p.parseBlockStmt()
+	for p.tok.Type == token.Catch {
+		c := new(CatchClause)
+		c.pos = p.tok.Pos
+		p.next()
+		p.expect(token.Lparen)
+		for {
+			c.Types = append(c.Types, p.parseName())
+			if !p.got(token.BitOr) {
+				break
+			}
+		}
+		if p.tok.Type == token.Var {
+			c.Var = p.parseVar()
+		}
+		p.expect(token.Rparen)
+		c.Body = p.parseBlockStmt()
+		c.end = p.lastEnd
+		s.Catches = append(s.Catches, c)
+	}
+	if p.got(token.Finally) {
+		s.Finally = p.parseBlockStmt()
+	}
+	if s.Catches == nil && s.Finally == nil {
+		p.errorf("expecting catch or finally, found %v", p.tok.Token)
+	}
+	return s
+}
+
+func (p *parser) parseDeclare() *DeclareStmt {
+	s := new(DeclareStmt)
+	p.expect(token.Declare)
+	p.expect(token.Lparen)
+	s.Directives = p.parseConstItems()
+	p.expect(token.Rparen)
+	switch p.tok.Type {
+	case token.Semicolon, token.CloseTag:
+		p.semi()
+	default:
+		s.Body, s.Alt = p.parseBodyOrAlt("enddeclare")
+	}
+	return s
+}
+
+func (p *parser) parseNamespace() *NamespaceStmt {
+	s := new(NamespaceStmt)
+	p.expect(token.Namespace)
+	if p.tok.Type != token.Lbrace {
+		s.Name = p.parseName()
+	}
+	if p.tok.Type == token.Lbrace {
+		s.Body = p.parseBlockStmt()
+	} else {
+		p.semi()
+	}
+	return s
+}
+
+func (p *parser) parseUse() *UseStmt {
+	s := new(UseStmt)
+	p.expect(token.Use)
+	s.Kind = p.parseUseKind()
+	first := p.parseUseItem(false)
+	if p.tok.Type == token.Lbrace {
+		// Group use.
+		s.Prefix = first.Name
+		p.next()
+		for p.tok.Type != token.Rbrace && p.tok.Type != token.EOF {
+			s.Items = append(s.Items, p.parseUseItem(s.Kind == ""))
+			if !p.got(token.Comma) {
+				break
+			}
+		}
+		p.expect(token.Rbrace)
+	} else {
+		s.Items = append(s.Items, first)
+		for p.got(token.Comma) {
+			s.Items = append(s.Items, p.parseUseItem(false))
+		}
+	}
+	p.semi()
+	return s
+}
+
+func (p *parser) parseUseKind() string {
+	switch p.tok.Type {
+	case token.Function, token.Const:
+		kind := strings.ToLower(p.tok.Text)
+		p.next()
+		return kind
+	}
+	return ""
+}
+
+func (p *parser) parseUseItem(kinded bool) *UseItem {
+	u := new(UseItem)
+	u.pos = p.tok.Pos
+	if kinded {
+		u.Kind = p.parseUseKind()
+	}
+	u.Name = p.parseName()
+	if p.got(token.As) {
+		u.Alias = p.parseIdent()
+	}
+	u.end = p.lastEnd
+	return u
+}
+
+// parseConstItems parses a list of NAME = value.
+func (p *parser) parseConstItems() []*ConstItem {
+	var items []*ConstItem
+	for {
+		c := new(ConstItem)
+		c.pos = p.tok.Pos
+		c.Name = p.parseIdent()
+		p.expect(token.Assign)
+		c.Value = p.parseExpr()
+		c.end = p.lastEnd
+		items = append(items, c)
+		if !p.got(token.Comma) {
+			return items
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Names
+
+// isIdent reports whether typ can be used as an identifier,
+// e.g. a name of a member.
+func isIdent(typ token.Type) bool {
+	return typ == token.Ident || typ.IsReserved()
+}
+
+func (p *parser) parseIdent() *Ident {
+	id := new(Ident)
+	id.pos = p.tok.Pos
+	if !isIdent(p.tok.Type) {
+		p.errorf("expecting name, found %v", p.tok.Token)
+		return id
+	}
+	id.Name = p.tok.Text
+	p.next()
+	id.end = p.lastEnd
+	return id
+}
+
+// parseName parses a possibly qualified name.
+func (p *parser) parseName() *Name {
+	n := new(Name)
+	n.pos = p.tok.Pos
+	n.Global = p.got(token.Backslash)
+	for {
+		if !isIdent(p.tok.Type) {
+			p.errorf("expecting name, found %v", p.tok.Token)
+			return n
+		}
+		n.Parts = append(n.Parts, p.tok.Text)
+		p.next()
+		if p.tok.Type != token.Backslash || !isIdent(p.peek(1)) {
+			break
+		}
+		p.next()
+	}
+	// The trailing \ of a group use prefix.
+	if p.tok.Type == token.Backslash && p.peek(1) == token.Lbrace {
+		p.next()
+	}
+	n.end = p.lastEnd
+	return n
+}
+
+func (p *parser) parseVar() *Var {
+	v := new(Var)
+	v.pos = p.tok.Pos
+	if p.tok.Type != token.Var {
+		p.errorf("expecting variable, found %v", p.tok.Token)
+		return v
+	}
+	v.Name = p.tok.Text[1:]
+	p.next()
+	v.end = p.lastEnd
+	return v
+}
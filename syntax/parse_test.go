@@ -0,0 +1,137 @@
+package syntax_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"mibk.dev/phpfmt/phpver"
+	"mibk.dev/phpfmt/syntax"
+)
+
+func parse(t *testing.T, src string) *syntax.File {
+	t.Helper()
+	f, err := syntax.Parse(strings.NewReader(src), phpver.Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestComments(t *testing.T) {
+	f := parse(t, `<?php
+// A is a class.
+class A
+{
+	/** The ID. */
+	public int $id; // not null
+
+	public function f(
+		$a, // the first one
+	) {
+		return [
+			// Some key.
+			'k' => 1,
+		];
+	}
+}
+// The end.
+`)
+	var got []string
+	syntax.Inspect(f, func(n syntax.Node) bool {
+		var doc []*syntax.Comment
+		var trailing *syntax.Comment
+		switch n := n.(type) {
+		case *syntax.ClassDecl:
+			doc, trailing = n.Doc, n.Trailing
+		case *syntax.PropertyDecl:
+			doc, trailing = n.Doc, n.Trailing
+		case *syntax.Param:
+			doc, trailing = n.Doc, n.Trailing
+		case *syntax.ArrayItem:
+			doc, trailing = n.Doc, n.Trailing
+		default:
+			return true
+		}
+		s := fmt.Sprintf("%T:", n)
+		for _, c := range doc {
+			s += " doc " + c.Text
+		}
+		if trailing != nil {
+			s += " trailing " + trailing.Text
+		}
+		got = append(got, s)
+		return true
+	})
+	for _, c := range f.End_ {
+		got = append(got, "end "+c.Text)
+	}
+	want := []string{
+		"*syntax.ClassDecl: doc // A is a class.",
+		"*syntax.PropertyDecl: doc /** The ID. */ trailing // not null",
+		"*syntax.Param: trailing // the first one",
+		"*syntax.ArrayItem: doc // Some key.",
+		"end // The end.",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestPositions(t *testing.T) {
+	f := parse(t, `<?php
+$a = foo(1,
+	$b->c);
+`)
+	var got []string
+	syntax.Inspect(f, func(n syntax.Node) bool {
+		if n != nil {
+			got = append(got, fmt.Sprintf("%T %v-%v", n, n.Pos(), n.End()))
+		}
+		return true
+	})
+	want := []string{
+		"*syntax.File 1:1-3:9",
+		"*syntax.ExprStmt 2:1-3:9",
+		"*syntax.AssignExpr 2:1-3:8",
+		"*syntax.Var 2:1-2:3",
+		"*syntax.CallExpr 2:6-3:8",
+		"*syntax.NameExpr 2:6-2:9",
+		"*syntax.Name 2:6-2:9",
+		"*syntax.ArgList 2:9-3:8",
+		"*syntax.Arg 2:10-2:11",
+		"*syntax.Lit 2:10-2:11",
+		"*syntax.Arg 3:2-3:7",
+		"*syntax.PropExpr 3:2-3:7",
+		"*syntax.Var 3:2-3:4",
+		"*syntax.Ident 3:6-3:7",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{"<?php\nfoo(", "line:2:5: expecting ), found EOF"},
+		{"<?php\nif ($a) {\n", "line:3:1: expecting }, found EOF"},
+		{"<?php\n$a = 1 2;", "line:2:8: expecting ;, found Int(\"2\")"},
+		{"<?php\nwhile ($a): endif;", "line:2:13: expecting endwhile, found Ident(\"endif\")"},
+		{"<?php\nif ($a): else if ($b): endif;", "line:2:15: unexpected if"},
+		{"<?php\nif ($a) {} elseif ($b): endif;", "line:2:23: unexpected :"},
+		{"<?php\nfoo();\nendif;", "line:3:1: unexpected Ident(\"endif\")"},
+	}
+	for _, tt := range tests {
+		_, err := syntax.Parse(strings.NewReader(tt.src), phpver.Latest)
+		if err == nil {
+			t.Errorf("%q: got no error, want %s", tt.src, tt.err)
+			continue
+		}
+		if err.Error() != tt.err {
+			t.Errorf("%q: got %s, want %s", tt.src, err, tt.err)
+		}
+	}
+}
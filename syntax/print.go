@@ -0,0 +1,1321 @@
+package syntax
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"mibk.dev/phpfmt/phpver"
+	"mibk.dev/phpfmt/token"
+)
+
+// A Config controls the output of Fprint.
+type Config struct {
+	// PHP is the PHP version the output is printed for.
+	// It decides where trailing commas may be added.
+	// The zero value means the latest version.
+	PHP phpver.Version
+}
+
+// Fprint prints a syntax tree node to w, for the latest PHP version.
+func Fprint(w io.Writer, node Node) error {
+	return new(Config).Fprint(w, node)
+}
+
+// Fprint prints a syntax tree node to w.
+//
+// The code is printed in a canonical style, indented by tabs.
+// The layout of the original code is kept only where it is recorded
+// in the tree: the blank lines between statements, multiline lists
+// (Multiline fields), the line breaks in chains of binary operators,
+// member accesses, and ternary operators, the statements on the same
+// line as an opening or a closing tag, and the empty bodies of closures
+// and anonymous classes written on a single line ({}).
+func (c *Config) Fprint(w io.Writer, node Node) error {
+	p := &printer{php: c.PHP}
+	p.node(node)
+	if p.err != nil {
+		return p.err
+	}
+	_, err := w.Write(p.buf.Bytes())
+	return err
+}
+
+type printer struct {
+	php phpver.Version
+	buf bytes.Buffer
+	err error
+
+	indent     int
+	needIndent bool // at the start of a line
+	cont       bool // in a continuation line, indented one more level
+}
+
+func (p *printer) print(s string) {
+	if p.needIndent {
+		for range p.indent {
+			p.buf.WriteByte('\t')
+		}
+		p.needIndent = false
+	}
+	// Normalize line endings to LF.
+	p.buf.WriteString(strings.ReplaceAll(s, "\r", ""))
+}
+
+func (p *printer) nl() {
+	p.buf.WriteByte('\n')
+	p.needIndent = true
+}
+
+// breakLine starts a continuation line of an expression.
+func (p *printer) breakLine() {
+	if !p.cont {
+		p.indent++
+		p.cont = true
+	}
+	p.nl()
+}
+
+// save saves the indentation, and starts a new expression
+// that is not continued yet. The returned function restores
+// the indentation.
+func (p *printer) save() (restore func()) {
+	indent, cont := p.indent, p.cont
+	p.cont = false
+	return func() { p.indent, p.cont = indent, cont }
+}
+
+func (p *printer) node(n Node) {
+	switch n := n.(type) {
+	case *File:
+		p.file(n)
+	case Stmt:
+		p.stmt(n)
+	case Expr:
+		p.expr(n)
+	case Type:
+		p.typ(n)
+	case *Name:
+		p.name(n)
+	case *Comment:
+		p.comment(n)
+	case *ArgList:
+		p.args(n)
+	case *ParamList:
+		p.params(n)
+	case *Param:
+		p.param(n)
+	case *AttrGroup:
+		p.attrGroup(n)
+	default:
+		p.err = fmt.Errorf("unsupported node %T", n)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Comments
+
+func isLineComment(c *Comment) bool {
+	return strings.HasPrefix(c.Text, "//") || strings.HasPrefix(c.Text, "#")
+}
+
+// comment prints c. The lines of a multiline comment are reindented.
+func (p *printer) comment(c *Comment) {
+	if isLineComment(c) {
+		p.print(strings.TrimRight(c.Text, " \t\r"))
+		return
+	}
+	lines := strings.Split(c.Text, "\n")
+	for i, line := range lines {
+		if i > 0 {
+			p.nl()
+			// Remove the original indentation.
+			for j := 1; j < c.pos.Column && line != ""; j++ {
+				if line[0] != ' ' && line[0] != '\t' {
+					break
+				}
+				line = line[1:]
+			}
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+		}
+		p.print(strings.TrimRight(line, " \t\r"))
+	}
+}
+
+// doc prints the comments in doc on separate lines.
+func (p *printer) doc(doc []*Comment, next Node) {
+	for i, c := range doc {
+		p.comment(c)
+		p.nl()
+		nextLine := startLine(next)
+		if i+1 < len(doc) {
+			nextLine = doc[i+1].pos.Line
+		}
+		if nextLine > c.end.Line+1 {
+			p.nl()
+		}
+	}
+}
+
+func (p *printer) trailing(c *Comment) {
+	if c != nil {
+		p.print(" ")
+		p.comment(c)
+	}
+}
+
+// startLine returns the line on which n, including
+// the comments before it, starts.
+func startLine(n Node) int {
+	if n, ok := n.(commentedNode); ok {
+		if doc := n.comments().Doc; len(doc) > 0 {
+			return doc[0].pos.Line
+		}
+	}
+	return n.Pos().Line
+}
+
+// hasLineComments reports whether c needs to be printed
+// on more lines.
+func (c *commented) hasLineComments() bool {
+	if c.Trailing != nil && isLineComment(c.Trailing) {
+		return true
+	}
+	for _, com := range c.Doc {
+		if isLineComment(com) || strings.Contains(com.Text, "\n") {
+			return true
+		}
+	}
+	return false
+}
+
+// ----------------------------------------------------------------------------
+// Statements
+
+func (p *printer) file(f *File) {
+	if h, ok := first(f.Stmts).(*InlineHTML); !ok || h.Close {
+		p.print("<?php")
+		switch {
+		case len(f.Stmts) > 0 && startLine(f.Stmts[0]) == f.Pos().Line:
+			// E.g. <?php declare(strict_types=1);
+			p.print(" ")
+		case len(f.Stmts) > 0 || len(f.End_) > 0:
+			p.nl()
+			p.nl()
+		default:
+			p.nl()
+		}
+	}
+	p.stmtList(f.Stmts, f.End_)
+	if n := len(f.Stmts); n > 0 {
+		if h, ok := f.Stmts[n-1].(*InlineHTML); ok && !h.Open && len(f.End_) == 0 {
+			// Keep the text after the closing tag intact.
+			return
+		}
+	}
+	p.nl()
+}
+
+// stmtList prints statements on separate lines, followed
+// by the comments in end. It keeps single blank lines
+// between the statements, and the statements written
+// on the same line as an opening or a closing tag.
+func (p *printer) stmtList(list []Stmt, end []*Comment) {
+	prevEnd := 0
+	for i, s := range list {
+		switch {
+		case i == 0:
+		case (opensTag(list[i-1]) || closesTag(s)) && startLine(s) == prevEnd:
+			p.print(" ")
+		default:
+			p.nl()
+			if startLine(s) > prevEnd+1 {
+				p.nl()
+			}
+		}
+		p.stmtWithComments(s)
+		prevEnd = s.End().Line
+	}
+	for i, c := range end {
+		if i > 0 || len(list) > 0 {
+			p.nl()
+			if c.pos.Line > prevEnd+1 {
+				p.nl()
+			}
+		}
+		p.comment(c)
+		prevEnd = c.end.Line
+	}
+}
+
+func first(list []Stmt) Stmt {
+	if len(list) == 0 {
+		return nil
+	}
+	return list[0]
+}
+
+// opensTag reports whether s ends with an opening tag, <?php.
+func opensTag(s Stmt) bool {
+	h, ok := s.(*InlineHTML)
+	return ok && h.Open
+}
+
+// closesTag reports whether s starts with a closing tag, ?>.
+func closesTag(s Stmt) bool {
+	h, ok := s.(*InlineHTML)
+	return ok && h.Close
+}
+
+func (p *printer) stmtWithComments(s Stmt) {
+	defer p.save()()
+	if s, ok := s.(commentedNode); ok {
+		p.doc(s.comments().Doc, s)
+	}
+	p.stmt(s)
+	if s, ok := s.(commentedNode); ok {
+		p.trailing(s.comments().Trailing)
+	}
+}
+
+// block prints statements in braces. The comments in doc,
+// which precede the block, are moved inside it.
+func (p *printer) block(list []Stmt, end []*Comment, doc ...*Comment) {
+	p.print("{")
+	p.indent++
+	for _, c := range doc {
+		p.nl()
+		p.comment(c)
+	}
+	if len(list) > 0 || len(end) > 0 {
+		p.nl()
+		p.stmtList(list, end)
+	}
+	p.indent--
+	p.nl()
+	p.print("}")
+}
+
+// body prints the body of a control structure. It reports whether
+// the body ends with a brace, which may be followed, e.g., by else.
+func (p *printer) body(s Stmt) (brace bool) {
+	if b, ok := s.(*BlockStmt); ok {
+		p.print(" ")
+		p.block(b.Stmts, b.End_, b.Doc...)
+		p.trailing(b.Trailing)
+		return b.Trailing == nil
+	}
+	p.indent++
+	p.nl()
+	p.stmtWithComments(s)
+	p.indent--
+	return false
+}
+
+// altBody prints the body of a control structure written in the
+// alternative syntax, up to the keyword on line end that follows it,
+// e.g. endwhile. A closing tag after the colon, and an opening tag
+// before the keyword, are kept on the same line.
+func (p *printer) altBody(s Stmt, end int) {
+	list, comments := []Stmt{s}, []*Comment(nil)
+	if b, ok := s.(*BlockStmt); ok {
+		list, comments = b.Stmts, b.End_
+	}
+	p.print(":")
+	p.indent++
+	if len(list) > 0 || len(comments) > 0 {
+		if closesTag(list[0]) && startLine(list[0]) == s.Pos().Line {
+			p.print(" ")
+		} else {
+			p.nl()
+		}
+		p.stmtList(list, comments)
+	}
+	p.indent--
+	if len(comments) == 0 && len(list) > 0 && opensTag(list[len(list)-1]) && list[len(list)-1].End().Line == end {
+		p.print(" ")
+	} else {
+		p.nl()
+	}
+}
+
+// cond prints the condition of a control structure in parentheses.
+func (p *printer) cond(x Expr) {
+	restore := p.save()
+	p.print("(")
+	p.expr(x)
+	p.print(")")
+	restore()
+}
+
+func (p *printer) stmt(s Stmt) {
+	switch s := s.(type) {
+	default:
+		p.err = fmt.Errorf("unsupported statement %T", s)
+	case *ExprStmt:
+		p.expr(s.X)
+		p.print(";")
+	case *EchoStmt:
+		p.print("echo ")
+		p.exprList(s.Exprs)
+		p.print(";")
+	case *InlineHTML:
+		if s.Close {
+			p.print("?>")
+		}
+		p.print(s.Text)
+		if s.Open {
+			p.print("<?php")
+		}
+	case *BlockStmt:
+		p.block(s.Stmts, s.End_)
+	case *EmptyStmt:
+		p.print(";")
+	case *IfStmt:
+		p.ifStmt(s)
+	case *WhileStmt:
+		p.print("while ")
+		p.cond(s.Cond)
+		if s.Alt {
+			p.altBody(s.Body, s.End().Line)
+			p.print("endwhile;")
+			break
+		}
+		p.body(s.Body)
+	case *DoWhileStmt:
+		p.print("do")
+		if p.body(s.Body) {
+			p.print(" ")
+		} else {
+			p.nl()
+		}
+		p.print("while ")
+		p.cond(s.Cond)
+		p.print(";")
+	case *ForStmt:
+		restore := p.save()
+		p.print("for (")
+		for i, list := range [][]Expr{s.Init, s.Cond, s.Post} {
+			if i > 0 {
+				p.print(";")
+				if len(list) > 0 {
+					p.print(" ")
+				}
+			}
+			p.exprList(list)
+		}
+		p.print(")")
+		restore()
+		if s.Alt {
+			p.altBody(s.Body, s.End().Line)
+			p.print("endfor;")
+			break
+		}
+		p.body(s.Body)
+	case *ForeachStmt:
+		restore := p.save()
+		p.print("foreach (")
+		p.expr(s.X)
+		p.print(" as ")
+		if s.Key != nil {
+			p.expr(s.Key)
+			p.print(" => ")
+		}
+		if s.ByRef {
+			p.print("&")
+		}
+		p.expr(s.Value)
+		p.print(")")
+		restore()
+		if s.Alt {
+			p.altBody(s.Body, s.End().Line)
+			p.print("endforeach;")
+			break
+		}
+		p.body(s.Body)
+	case *SwitchStmt:
+		p.print("switch ")
+		p.cond(s.Tag)
+		if s.Alt {
+			p.print(":")
+		} else {
+			p.print(" {")
+		}
+		p.indent++
+		for _, c := range s.Cases {
+			p.nl()
+			p.doc(c.Doc, c)
+			if c.Expr != nil {
+				restore := p.save()
+				p.print("case ")
+				p.expr(c.Expr)
+				restore()
+			} else {
+				p.print("default")
+			}
+			p.print(":")
+			p.trailing(c.Trailing)
+			if len(c.Body) > 0 {
+				p.indent++
+				p.nl()
+				p.stmtList(c.Body, nil)
+				p.indent--
+			}
+		}
+		for _, c := range s.End_ {
+			p.nl()
+			p.comment(c)
+		}
+		p.indent--
+		p.nl()
+		if s.Alt {
+			p.print("endswitch;")
+		} else {
+			p.print("}")
+		}
+	case *BranchStmt:
+		p.print(s.Tok.String())
+		if s.Depth != nil {
+			p.print(" ")
+			p.expr(s.Depth)
+		}
+		p.print(";")
+	case *ReturnStmt:
+		p.print("return")
+		if s.X != nil {
+			p.print(" ")
+			p.expr(s.X)
+		}
+		p.print(";")
+	case *GlobalStmt:
+		p.print("global ")
+		p.exprList(s.Vars)
+		p.print(";")
+	case *StaticStmt:
+		p.print("static ")
+		for i, v := range s.Vars {
+			if i > 0 {
+				p.print(", ")
+			}
+			p.expr(v.Var)
+			if v.Default != nil {
+				p.print(" = ")
+				p.expr(v.Default)
+			}
+		}
+		p.print(";")
+	case *UnsetStmt:
+		p.print("unset(")
+		p.exprList(s.Exprs)
+		p.print(");")
+	case *GotoStmt:
+		p.print("goto " + s.Label.Name + ";")
+	case *LabelStmt:
+		p.print(s.Label.Name + ":")
+	case *TryStmt:
+		p.print("try ")
+		p.block(s.Body.Stmts, s.Body.End_)
+		for _, c := range s.Catches {
+			p.print(" catch (")
+			for i, t := range c.Types {
+				if i > 0 {
+					p.print(" | ")
+				}
+				p.name(t)
+			}
+			if c.Var != nil {
+				p.print(" ")
+				p.expr(c.Var)
+			}
+			p.print(") ")
+			p.block(c.Body.Stmts, c.Body.End_)
+		}
+		if s.Finally != nil {
+			p.print(" finally ")
+			p.block(s.Finally.Stmts, s.Finally.End_)
+		}
+	case *DeclareStmt:
+		p.print("declare(")
+		for i, d := range s.Directives {
+			if i > 0 {
+				p.print(", ")
+			}
+			p.print(d.Name.Name + "=")
+			p.expr(d.Value)
+		}
+		p.print(")")
+		switch {
+		case s.Body == nil:
+			p.print(";")
+		case s.Alt:
+			p.altBody(s.Body, s.End().Line)
+			p.print("enddeclare;")
+		default:
+			p.body(s.Body)
+		}
+	case *NamespaceStmt:
+		p.print("namespace")
+		if s.Name != nil {
+			p.print(" ")
+			p.name(s.Name)
+		}
+		if s.Body == nil {
+			p.print(";")
+		} else {
+			p.print(" ")
+			p.block(s.Body.Stmts, s.Body.End_)
+		}
+	case *UseStmt:
+		p.print("use ")
+		if s.Kind != "" {
+			p.print(s.Kind + " ")
+		}
+		if s.Prefix != nil {
+			p.name(s.Prefix)
+			p.print(`\{`)
+		}
+		for i, u := range s.Items {
+			if i > 0 {
+				p.print(", ")
+			}
+			if u.Kind != "" {
+				p.print(u.Kind + " ")
+			}
+			p.name(u.Name)
+			if u.Alias != nil {
+				p.print(" as " + u.Alias.Name)
+			}
+		}
+		if s.Prefix != nil {
+			p.print("}")
+		}
+		p.print(";")
+	case *ConstStmt:
+		p.print("const ")
+		p.constItems(s.Items)
+		p.print(";")
+	case *FuncDecl:
+		p.attrs(s.Attrs, false)
+		p.print("function ")
+		p.function(s.ByRef, s.Name, s.Params, s.Result)
+		p.nl()
+		p.block(s.Body.Stmts, s.Body.End_)
+	case *ClassDecl:
+		p.attrs(s.Attrs, false)
+		p.class(s)
+	case *MethodDecl:
+		p.attrs(s.Attrs, false)
+		p.modifiers(s.Modifiers)
+		p.print("function ")
+		p.function(s.ByRef, s.Name, s.Params, s.Result)
+		if s.Body == nil {
+			p.print(";")
+			break
+		}
+		p.nl()
+		p.block(s.Body.Stmts, s.Body.End_)
+	case *PropertyDecl:
+		p.attrs(s.Attrs, false)
+		p.modifiers(s.Modifiers)
+		if s.Type != nil {
+			p.typ(s.Type)
+			p.print(" ")
+		}
+		for i, it := range s.Props {
+			if i > 0 {
+				p.print(", ")
+			}
+			p.expr(it.Var)
+			if it.Default != nil {
+				p.print(" = ")
+				p.expr(it.Default)
+			}
+		}
+		if s.Hooks == nil {
+			p.print(";")
+			break
+		}
+		p.nl()
+		p.hooks(s.Hooks)
+	case *ClassConstDecl:
+		p.attrs(s.Attrs, false)
+		p.modifiers(s.Modifiers)
+		p.print("const ")
+		if s.Type != nil {
+			p.typ(s.Type)
+			p.print(" ")
+		}
+		p.constItems(s.Items)
+		p.print(";")
+	case *EnumCase:
+		p.attrs(s.Attrs, false)
+		p.print("case " + s.Name.Name)
+		if s.Value != nil {
+			p.print(" = ")
+			p.expr(s.Value)
+		}
+		p.print(";")
+	case *TraitUse:
+		p.print("use ")
+		p.nameList(s.Traits)
+		if s.Rules == nil {
+			p.print(";")
+			break
+		}
+		p.nl()
+		p.print("{")
+		p.indent++
+		for _, r := range s.Rules {
+			p.nl()
+			if r.Trait != nil {
+				p.name(r.Trait)
+				p.print("::")
+			}
+			p.print(r.Method.Name)
+			if r.Insteadof != nil {
+				p.print(" insteadof ")
+				p.nameList(r.Insteadof)
+			} else {
+				p.print(" as")
+				if r.Modifier != "" {
+					p.print(" " + r.Modifier)
+				}
+				if r.Alias != nil {
+					p.print(" " + r.Alias.Name)
+				}
+			}
+			p.print(";")
+		}
+		p.indent--
+		p.nl()
+		p.print("}")
+	}
+}
+
+func (p *printer) ifStmt(s *IfStmt) {
+	p.print("if ")
+	p.cond(s.Cond)
+	if s.Alt {
+		p.altIf(s)
+		return
+	}
+	brace := p.body(s.Then)
+	switch e := s.Else.(type) {
+	case nil:
+		return
+	case *IfStmt:
+		if brace && e.Doc == nil {
+			p.print(" ")
+		} else {
+			p.nl()
+			p.doc(e.Doc, e)
+		}
+		if s.ElseIf {
+			p.print("else")
+		} else {
+			p.print("else ")
+		}
+		p.ifStmt(e)
+		p.trailing(e.Trailing)
+	default:
+		if brace {
+			p.print(" ")
+		} else {
+			p.nl()
+		}
+		p.print("else")
+		p.body(e)
+	}
+}
+
+// altIf prints the rest of an if statement written
+// in the alternative syntax, after its condition.
+func (p *printer) altIf(s *IfStmt) {
+	switch e := s.Else.(type) {
+	case nil:
+		p.altBody(s.Then, s.End().Line)
+		p.print("endif;")
+	case *IfStmt:
+		p.altBody(s.Then, e.Pos().Line)
+		p.doc(e.Doc, e)
+		p.print("else")
+		p.ifStmt(e)
+		p.trailing(e.Trailing)
+	default:
+		p.altBody(s.Then, e.Pos().Line)
+		p.print("else")
+		p.altBody(e, s.End().Line)
+		p.print("endif;")
+	}
+}
+
+func (p *printer) constItems(items []*ConstItem) {
+	for i, c := range items {
+		if i > 0 {
+			p.print(", ")
+		}
+		p.print(c.Name.Name + " = ")
+		p.expr(c.Value)
+	}
+}
+
+func (p *printer) nameList(list []*Name) {
+	for i, n := range list {
+		if i > 0 {
+			p.print(", ")
+		}
+		p.name(n)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Declarations
+
+// attrs prints groups of attributes, either each on its own line,
+// or on the same line as the declaration.
+func (p *printer) attrs(groups []*AttrGroup, inline bool) {
+	for _, g := range groups {
+		p.attrGroup(g)
+		if inline {
+			p.print(" ")
+		} else {
+			p.nl()
+		}
+	}
+}
+
+func (p *printer) attrGroup(g *AttrGroup) {
+	p.print("#[")
+	for i, a := range g.Attrs {
+		if i > 0 {
+			p.print(", ")
+		}
+		p.name(a.Name)
+		if a.Args != nil {
+			p.args(a.Args)
+		}
+	}
+	p.print("]")
+}
+
+func (p *printer) modifiers(mods []string) {
+	for _, m := range mods {
+		p.print(m + " ")
+	}
+}
+
+// function prints the signature of a function after the keyword.
+func (p *printer) function(byRef bool, name *Ident, params *ParamList, result Type) {
+	switch {
+	case name != nil && byRef:
+		p.print("&" + name.Name)
+	case name != nil:
+		p.print(name.Name)
+	case byRef:
+		p.print(" &")
+	}
+	p.params(params)
+	if result != nil {
+		p.print(": ")
+		p.typ(result)
+	}
+}
+
+func (p *printer) params(l *ParamList) {
+	printList(p, "(", ")", l.Params, l.Multiline, p.php.AtLeast(phpver.PHP80), p.param)
+}
+
+func (p *printer) param(x *Param) {
+	p.attrs(x.Attrs, true)
+	p.modifiers(x.Modifiers)
+	if x.Type != nil {
+		p.typ(x.Type)
+		p.print(" ")
+	}
+	if x.ByRef {
+		p.print("&")
+	}
+	if x.Variadic {
+		p.print("...")
+	}
+	p.expr(x.Var)
+	if x.Default != nil {
+		p.print(" = ")
+		p.expr(x.Default)
+	}
+	if x.Hooks != nil {
+		p.print(" ")
+		p.hooks(x.Hooks)
+	}
+}
+
+func (p *printer) hooks(hooks []*PropHook) {
+	p.print("{")
+	p.indent++
+	for _, h := range hooks {
+		p.nl()
+		p.attrs(h.Attrs, false)
+		p.modifiers(h.Modifiers)
+		if h.ByRef {
+			p.print("&")
+		}
+		p.print(h.Name.Name)
+		if h.Params != nil {
+			p.params(h.Params)
+		}
+		switch body := h.Body.(type) {
+		case nil:
+			p.print(";")
+		case *BlockStmt:
+			p.print(" ")
+			p.block(body.Stmts, body.End_)
+		case Expr:
+			p.print(" => ")
+			p.expr(body)
+			p.print(";")
+		}
+	}
+	p.indent--
+	p.nl()
+	p.print("}")
+}
+
+func (p *printer) class(c *ClassDecl) {
+	p.modifiers(c.Modifiers)
+	p.print(c.Kind.String())
+	if c.Name != nil {
+		p.print(" " + c.Name.Name)
+	}
+	if c.Args != nil {
+		p.args(c.Args)
+	}
+	if c.EnumType != nil {
+		p.print(": ")
+		p.typ(c.EnumType)
+	}
+	if c.Extends != nil {
+		p.print(" extends ")
+		p.nameList(c.Extends)
+	}
+	if c.Implements != nil {
+		p.print(" implements ")
+		p.nameList(c.Implements)
+	}
+	switch {
+	case c.Name != nil:
+		p.nl()
+	case len(c.Members) == 0 && len(c.End_) == 0 && c.Pos().Line == c.End().Line:
+		p.print(" {}")
+		return
+	default:
+		p.print(" ")
+	}
+	p.block(c.Members, c.End_)
+}
+
+// ----------------------------------------------------------------------------
+// Types and names
+
+func (p *printer) typ(t Type) {
+	switch t := t.(type) {
+	default:
+		p.err = fmt.Errorf("unsupported type %T", t)
+	case *NamedType:
+		p.name(t.Name)
+	case *NullableType:
+		p.print("?")
+		p.typ(t.Type)
+	case *UnionType:
+		for i, t := range t.Types {
+			if i > 0 {
+				p.print("|")
+			}
+			p.typ(t)
+		}
+	case *IntersectionType:
+		for i, t := range t.Types {
+			if i > 0 {
+				p.print("&")
+			}
+			p.typ(t)
+		}
+	case *ParenType:
+		p.print("(")
+		p.typ(t.Type)
+		p.print(")")
+	}
+}
+
+func (p *printer) name(n *Name) {
+	if n.Global {
+		p.print(`\`)
+	}
+	p.print(strings.Join(n.Parts, `\`))
+}
+
+// ----------------------------------------------------------------------------
+// Expressions
+
+func (p *printer) exprList(list []Expr) {
+	for i, x := range list {
+		if i > 0 {
+			p.print(", ")
+		}
+		p.expr(x)
+	}
+}
+
+func (p *printer) expr(x Expr) {
+	switch x := x.(type) {
+	default:
+		p.err = fmt.Errorf("unsupported expression %T", x)
+	case *BadExpr:
+	case *Ident:
+		p.print(x.Name)
+	case *Var:
+		p.print("$" + x.Name)
+	case *VarVar:
+		p.print("$")
+		if x.Brace {
+			p.print("{")
+			p.expr(x.X)
+			p.print("}")
+		} else {
+			p.expr(x.X)
+		}
+	case *NameExpr:
+		p.name(x.Name)
+	case *Lit:
+		p.print(x.Value)
+	case *ArrayLit:
+		open, close := "[", "]"
+		switch x.Kind {
+		case LongArray:
+			open, close = "array(", ")"
+		case ListArray:
+			open, close = "list(", ")"
+		}
+		printList(p, open, close, x.Items, x.Multiline, true, p.arrayItem)
+	case *ParenExpr:
+		p.print("(")
+		p.expr(x.X)
+		p.print(")")
+	case *UnaryExpr:
+		p.print(x.Op.String())
+		if (x.Op == token.Sub || x.Op == token.Add) && startsWith(x.X, x.Op) {
+			// Not to print --$x or ++$x.
+			p.print(" ")
+		}
+		p.expr(x.X)
+	case *IncDecExpr:
+		if x.Post {
+			p.expr(x.X)
+			p.print(x.Op.String())
+		} else {
+			p.print(x.Op.String())
+			p.expr(x.X)
+		}
+	case *BinaryExpr:
+		p.expr(x.X)
+		p.breakOr(x.X, x.Y, " ")
+		p.print(x.Op.String() + " ")
+		p.expr(x.Y)
+	case *AssignExpr:
+		p.expr(x.X)
+		p.print(" " + x.Op.String() + " ")
+		if x.ByRef {
+			p.print("&")
+		}
+		p.expr(x.Y)
+	case *TernaryExpr:
+		p.expr(x.Cond)
+		last := Node(x.Cond)
+		if x.Then != nil {
+			p.breakOr(x.Cond, x.Then, " ")
+			p.print("? ")
+			p.expr(x.Then)
+			last = x.Then
+			p.breakOr(last, x.Else, " ")
+			p.print(": ")
+		} else {
+			p.breakOr(last, x.Else, " ")
+			p.print("?: ")
+		}
+		p.expr(x.Else)
+	case *CastExpr:
+		p.print("(" + x.Type + ") ")
+		p.expr(x.X)
+	case *InstanceofExpr:
+		p.expr(x.X)
+		p.print(" instanceof ")
+		p.expr(x.Class)
+	case *CallExpr:
+		p.expr(x.Fun)
+		p.args(x.Args)
+	case *PropExpr:
+		p.expr(x.X)
+		p.breakOr(x.X, x.Name, "")
+		if x.NullSafe {
+			p.print("?->")
+		} else {
+			p.print("->")
+		}
+		p.expr(x.Name)
+	case *StaticExpr:
+		p.expr(x.Class)
+		p.breakOr(x.Class, x.Name, "")
+		p.print("::")
+		p.expr(x.Name)
+	case *BraceExpr:
+		p.print("{")
+		p.expr(x.X)
+		p.print("}")
+	case *IndexExpr:
+		p.expr(x.X)
+		p.print("[")
+		if x.Index != nil {
+			p.expr(x.Index)
+		}
+		p.print("]")
+	case *NewExpr:
+		p.print("new ")
+		switch c := x.Class.(type) {
+		case *ClassDecl:
+			p.attrs(c.Attrs, true)
+			p.class(c)
+		case Expr:
+			p.expr(c)
+		}
+		if x.Args != nil {
+			p.args(x.Args)
+		}
+	case *CloneExpr:
+		p.print("clone ")
+		p.expr(x.X)
+	case *PrintExpr:
+		p.print("print ")
+		p.expr(x.X)
+	case *ThrowExpr:
+		p.print("throw ")
+		p.expr(x.X)
+	case *YieldExpr:
+		p.print("yield")
+		if x.Key != nil {
+			p.print(" ")
+			p.expr(x.Key)
+			p.print(" =>")
+		}
+		if x.Value != nil {
+			p.print(" ")
+			p.expr(x.Value)
+		}
+	case *YieldFromExpr:
+		p.print("yield from ")
+		p.expr(x.X)
+	case *IncludeExpr:
+		p.print(x.Kind + " ")
+		p.expr(x.X)
+	case *ClosureExpr:
+		p.attrs(x.Attrs, true)
+		if x.Static {
+			p.print("static ")
+		}
+		p.print("function")
+		p.function(x.ByRef, nil, x.Params, nil)
+		if x.Uses != nil {
+			p.print(" use (")
+			for i, u := range x.Uses {
+				if i > 0 {
+					p.print(", ")
+				}
+				if u.ByRef {
+					p.print("&")
+				}
+				p.expr(u.Var)
+			}
+			p.print(")")
+		}
+		if x.Result != nil {
+			p.print(": ")
+			p.typ(x.Result)
+		}
+		b := x.Body
+		if len(b.Stmts) == 0 && len(b.End_) == 0 && b.Pos().Line == b.End().Line {
+			p.print(" {}")
+			break
+		}
+		p.print(" ")
+		p.block(b.Stmts, b.End_)
+	case *ArrowFuncExpr:
+		p.attrs(x.Attrs, true)
+		if x.Static {
+			p.print("static ")
+		}
+		p.print("fn")
+		p.function(x.ByRef, nil, x.Params, x.Result)
+		p.print(" => ")
+		p.expr(x.Body)
+	case *MatchExpr:
+		p.print("match ")
+		p.cond(x.Subject)
+		p.print(" ")
+		if len(x.Arms) == 0 {
+			p.print("{}")
+			break
+		}
+		printList(p, "{", "}", x.Arms, true, true, p.matchArm)
+	}
+}
+
+// breakOr starts a continuation line if y starts on a later line
+// than x ends in the original code. Otherwise, it prints sep.
+func (p *printer) breakOr(x, y Node, sep string) {
+	if y.Pos().Line > x.End().Line {
+		p.breakLine()
+	} else {
+		p.print(sep)
+	}
+}
+
+// startsWith reports whether x, when printed,
+// starts with the unary operator op.
+func startsWith(x Expr, op token.Type) bool {
+	for {
+		switch y := x.(type) {
+		case *UnaryExpr:
+			return y.Op == op
+		case *IncDecExpr:
+			if !y.Post {
+				return y.Op == token.Dec && op == token.Sub || y.Op == token.Inc && op == token.Add
+			}
+			x = y.X
+		case *BinaryExpr:
+			x = y.X
+		case *AssignExpr:
+			x = y.X
+		case *TernaryExpr:
+			x = y.Cond
+		case *InstanceofExpr:
+			x = y.X
+		case *CallExpr:
+			x = y.Fun
+		case *PropExpr:
+			x = y.X
+		case *StaticExpr:
+			x = y.Class
+		case *IndexExpr:
+			x = y.X
+		default:
+			return false
+		}
+	}
+}
+
+func (p *printer) args(l *ArgList) {
+	if l.Callable {
+		p.print("(...)")
+		return
+	}
+	printList(p, "(", ")", l.Args, l.Multiline, p.php.AtLeast(phpver.PHP73), p.arg)
+}
+
+func (p *printer) arg(a *Arg) {
+	if a.Name != nil {
+		p.print(a.Name.Name + ": ")
+	}
+	if a.Unpack {
+		p.print("...")
+	}
+	p.expr(a.Value)
+}
+
+func (p *printer) arrayItem(it *ArrayItem) {
+	if it.Key != nil {
+		p.expr(it.Key)
+		p.print(" => ")
+	}
+	switch {
+	case it.ByRef:
+		p.print("&")
+	case it.Unpack:
+		p.print("...")
+	}
+	p.expr(it.Value)
+}
+
+func (p *printer) matchArm(arm *MatchArm) {
+	if arm.Conds == nil {
+		p.print("default")
+	} else {
+		p.exprList(arm.Conds)
+	}
+	p.print(" => ")
+	p.expr(arm.Body)
+}
+
+// printList prints a list of items in brackets, either on a single
+// line, or each item on its own line, followed by a comma if comma
+// is set. The items with line comments are always printed on separate
+// lines. Nil items are printed as empty ones, e.g. in [, $b] = $x.
+func printList[T interface {
+	comparable
+	commentedNode
+}](p *printer, open, close string, items []T, multiline, comma bool, item func(T)) {
+	var zero T
+	for _, x := range items {
+		if x != zero && x.comments().hasLineComments() {
+			multiline = true
+		}
+	}
+	p.print(open)
+	if !multiline {
+		for i, x := range items {
+			if i > 0 {
+				p.print(" ")
+			}
+			if x != zero {
+				for _, c := range x.comments().Doc {
+					p.comment(c)
+					p.print(" ")
+				}
+				item(x)
+			}
+			if i < len(items)-1 || x == zero {
+				p.print(",")
+			}
+			if x != zero {
+				p.trailing(x.comments().Trailing)
+			}
+		}
+		p.print(close)
+		return
+	}
+
+	p.indent++
+	prevEnd := 0
+	for i, x := range items {
+		p.nl()
+		if x == zero {
+			p.print(",")
+			continue
+		}
+		if i > 0 && startLine(x) > prevEnd+1 {
+			p.nl()
+		}
+		restore := p.save()
+		p.doc(x.comments().Doc, x)
+		item(x)
+		restore()
+		if i < len(items)-1 || comma {
+			p.print(",")
+		}
+		p.trailing(x.comments().Trailing)
+		prevEnd = x.End().Line
+	}
+	p.indent--
+	p.nl()
+	p.print(close)
+}
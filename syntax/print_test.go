@@ -0,0 +1,449 @@
+package syntax_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"mibk.dev/phpfmt/phpver"
+	"mibk.dev/phpfmt/syntax"
+	"mibk.dev/phpfmt/token"
+)
+
+var printTests = []struct {
+	name string
+	test string
+}{
+	{"basic", `
+<?php
+namespace   Foo ;
+use A\B , C as D;
+function   &foo( int|null $a,  ...$rest ) : ?Bar{return $a+ 1;}
+----
+<?php
+
+namespace Foo;
+use A\B, C as D;
+function &foo(int|null $a, ...$rest): ?Bar
+{
+	return $a + 1;
+}
+`},
+	{"blank lines", `
+<?php
+
+$a = 1;
+
+
+$b = 2;
+$c = 3;
+----
+<?php
+
+$a = 1;
+
+$b = 2;
+$c = 3;
+`},
+	{"control structures", `
+<?php
+if($a){foo();}elseif($b)bar();else if ($c) {} else {baz();}
+while ($i--) { continue 2; }
+do { $i++; } while ($i < 10);
+for ($i = 0, $j = 1;;$i++) {}
+foreach ($list as $k => &$v) {}
+switch ($x) { case 1: case 2; foo(); break; default: bar(); }
+try { a(); } catch (A|B $e) { } catch (C) {} finally { b(); }
+----
+<?php
+
+if ($a) {
+	foo();
+} elseif ($b)
+	bar();
+else if ($c) {
+} else {
+	baz();
+}
+while ($i--) {
+	continue 2;
+}
+do {
+	$i++;
+} while ($i < 10);
+for ($i = 0, $j = 1;; $i++) {
+}
+foreach ($list as $k => &$v) {
+}
+switch ($x) {
+	case 1:
+	case 2:
+		foo();
+		break;
+	default:
+		bar();
+}
+try {
+	a();
+} catch (A | B $e) {
+} catch (C) {
+} finally {
+	b();
+}
+`},
+	{"expressions", `
+<?php
+$a = !$b instanceof C && - -$x ** 2 ?: (int)$y ?? $z;
+$f = static fn(int $x): int => $x*2;
+$g = function ($a) use (&$b) { return $a <=> $b; };
+$h = match(true) { $a, $b => 1, default => throw new E('no'), };
+$o = new class(1) extends B implements C { public function __construct(private readonly int $x) {} };
+echo $a?->b::$c[0]->{$d}(...$e, name: 1), A::class, $$x, ${'y'};
+[, $b, 'k' => [$c]] = $list;
+----
+<?php
+
+$a = !$b instanceof C && - -$x ** 2 ?: (int) $y ?? $z;
+$f = static fn(int $x): int => $x * 2;
+$g = function($a) use (&$b) {
+	return $a <=> $b;
+};
+$h = match (true) {
+	$a, $b => 1,
+	default => throw new E('no'),
+};
+$o = new class(1) extends B implements C {
+	public function __construct(private readonly int $x)
+	{
+	}
+};
+echo $a?->b::$c[0]->{$d}(...$e, name: 1), A::class, $$x, ${'y'};
+[, $b, 'k' => [$c]] = $list;
+`},
+	{"class", `
+<?php
+#[Attr(1)]
+final class A extends B implements C, D {
+use T1, T2 { T1::foo insteadof T2; bar as protected baz; }
+const int X = 1, Y = 2;
+public private(set) ?string $name = null;
+public string $full { get => $this->first . $this->last; set(string $v) { $this->first = $v; } }
+abstract protected function foo(): static;
+}
+enum Suit: string { case Hearts = 'H'; case Spades = 'S'; }
+----
+<?php
+
+#[Attr(1)]
+final class A extends B implements C, D
+{
+	use T1, T2
+	{
+		T1::foo insteadof T2;
+		bar as protected baz;
+	}
+	const int X = 1, Y = 2;
+	public private(set) ?string $name = null;
+	public string $full
+	{
+		get => $this->first . $this->last;
+		set(string $v) {
+			$this->first = $v;
+		}
+	}
+	abstract protected function foo(): static;
+}
+enum Suit: string
+{
+	case Hearts = 'H';
+	case Spades = 'S';
+}
+`},
+	{"comments", `
+<?php
+// Foo does things.
+foo(); // trailing
+
+/** Doc. */
+function f(
+	$a, // first
+	$b
+) {
+	// nothing
+}
+$x = [1, /* two */ 2];
+----
+<?php
+
+// Foo does things.
+foo(); // trailing
+
+/** Doc. */
+function f(
+	$a, // first
+	$b,
+)
+{
+	// nothing
+}
+$x = [1, /* two */ 2];
+`},
+	{"multiline", `
+<?php
+$x = $a
+->b()
+	->c();
+$y = foo(
+1, 2);
+$z = $a &&
+	$b;
+----
+<?php
+
+$x = $a
+	->b()
+	->c();
+$y = foo(
+	1,
+	2,
+);
+$z = $a
+	&& $b;
+`},
+	{"html", `
+<html><?php if ($a): ?><?php endif ?>
+----
+<html><?php if ($a): ?><?php endif; ?>
+`},
+	{"inline html", `
+<p><?php echo $a ?></p>
+<?php foo();
+if ($a) { ?>
+<b>
+<?php }
+----
+<p><?php echo $a; ?></p>
+<?php foo();
+if ($a) {
+	?>
+<b>
+<?php
+}
+`},
+	{"alternative syntax", `
+<?php
+if ($a): foo();
+elseif ($b):
+else: bar(); endif;
+while ($i--): endwhile;
+for (;;): break; endfor;
+foreach ($list as $v): ?>
+	<li><?= $v ?></li>
+<?php endforeach;
+switch ($x): case 1: foo(); ENDSWITCH;
+declare(ticks=1): enddeclare;
+----
+<?php
+
+if ($a):
+	foo();
+elseif ($b):
+else:
+	bar();
+endif;
+while ($i--):
+endwhile;
+for (;;):
+	break;
+endfor;
+foreach ($list as $v): ?>
+	<li><?= $v ?></li>
+<?php endforeach;
+switch ($x):
+	case 1:
+		foo();
+endswitch;
+declare(ticks=1):
+enddeclare;
+`},
+	{"empty bodies", `
+<?php
+$f = function () {};
+$g = function () {
+};
+$o = new class(1) extends B {};
+$p = new class {
+};
+----
+<?php
+
+$f = function() {};
+$g = function() {
+};
+$o = new class(1) extends B {};
+$p = new class {
+};
+`},
+	{"shell commands", `
+<?php
+$a = ` + "`ls -l $dir`" + `;
+----
+<?php
+
+$a = ` + "`ls -l $dir`" + `;
+`},
+}
+
+func TestPrint(t *testing.T) {
+	for _, tt := range printTests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, want, ok := strings.Cut(tt.test, "----\n")
+			if !ok {
+				t.Fatal("invalid test")
+			}
+			input = strings.TrimPrefix(input, "\n")
+			f, err := syntax.Parse(strings.NewReader(input), phpver.Latest)
+			if err != nil {
+				want = strings.TrimSpace(want)
+				if got := "error: " + err.Error(); got != want {
+					t.Fatalf("got %s, want %s", got, want)
+				}
+				return
+			}
+			var buf bytes.Buffer
+			if err := syntax.Fprint(&buf, f); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+// Files with code that is not valid PHP, or uses unsupported syntax.
+var skipFiles = []string{
+	"basic.golden",
+	"braces.golden",
+	"doc.golden",
+	"misc.golden",
+	"spaces.golden",
+	"ternary.golden",
+	"typehint.golden",
+}
+
+// TestRoundTrip checks that printing the files in the testdata
+// of the formatter keeps the code equivalent, and that printing
+// the printed code gives the same result.
+func TestRoundTrip(t *testing.T) {
+	files, err := filepath.Glob("../testdata/*.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if slices.Contains(skipFiles, filepath.Base(file)) {
+			continue
+		}
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ver := phpver.Latest
+			if v, ok := strings.CutPrefix(string(src), "<?php // PHP "); ok {
+				var n int
+				for _, r := range v {
+					if r < '0' || r > '9' {
+						break
+					}
+					n = n*10 + int(r-'0')
+				}
+				ver = phpver.Version(n)
+			}
+			cfg := &syntax.Config{PHP: ver}
+			out := roundTrip(t, cfg, src, ver)
+			if t.Failed() {
+				return
+			}
+			checkEquivalent(t, src, out, ver)
+			if out2 := roundTrip(t, cfg, out, ver); !bytes.Equal(out, out2) {
+				t.Errorf("not idempotent; got:\n%s\nthen:\n%s", out, out2)
+			}
+		})
+	}
+}
+
+func roundTrip(t *testing.T, cfg *syntax.Config, src []byte, ver phpver.Version) []byte {
+	t.Helper()
+	f, err := syntax.Parse(bytes.NewReader(src), ver)
+	if err != nil {
+		t.Fatalf("%s\n%s", err, src)
+	}
+	var buf bytes.Buffer
+	if err := cfg.Fprint(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// checkEquivalent compares the significant tokens of a and b,
+// ignoring the trailing commas, and the semicolons before ?>.
+func checkEquivalent(t *testing.T, a, b []byte, ver phpver.Version) {
+	t.Helper()
+	ta, tb := sigTokens(a, ver), sigTokens(b, ver)
+	for i := range max(len(ta), len(tb)) {
+		if i >= len(ta) || i >= len(tb) || ta[i] != tb[i] {
+			var x, y string
+			if i < len(ta) {
+				x = ta[i]
+			}
+			if i < len(tb) {
+				y = tb[i]
+			}
+			t.Fatalf("token %d: got %s, want %s; output:\n%s", i, y, x, b)
+		}
+	}
+}
+
+func sigTokens(src []byte, ver phpver.Version) []string {
+	var toks []token.Token
+	scan := token.NewScanner(bytes.NewReader(src), ver)
+	for {
+		tok := scan.Next()
+		if tok.Type == token.EOF {
+			break
+		}
+		switch tok.Type {
+		case token.Whitespace, token.Comment, token.DocComment:
+			continue
+		case token.InlineHTML:
+			tok.Text = strings.ReplaceAll(tok.Text, "\r", "")
+		}
+		toks = append(toks, tok)
+	}
+	var s []string
+	for i, tok := range toks {
+		var next token.Type
+		if i+1 < len(toks) {
+			next = toks[i+1].Type
+		}
+		switch {
+		case tok.Type == token.Comma && (next == token.Rparen || next == token.Rbrack || next == token.Rbrace):
+			continue
+		case tok.Type == token.Semicolon && next == token.CloseTag:
+			continue
+		}
+		text := tok.Text
+		if tok.Type.IsKeyword() || tok.Type > 0 && tok.Type < token.Ident {
+			text = strings.ToLower(text)
+		}
+		if tok.Type == token.Neq {
+			text = "!="
+		}
+		s = append(s, tok.Type.String()+" "+text)
+	}
+	return s
+}
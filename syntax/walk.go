@@ -0,0 +1,316 @@
+package syntax
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses a syntax tree in depth-first order: It starts by
+// calling v.Visit(node); node must not be nil. If the visitor w
+// returned by v.Visit(node) is not nil, Walk is invoked recursively
+// with visitor w for each of the non-nil children of node, followed
+// by a call of w.Visit(nil). Comments are not visited.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *File:
+		walkList(v, n.Stmts)
+
+	// Names and attributes
+	case *Name, *Ident, *Comment:
+		// nothing to do
+	case *AttrGroup:
+		walkList(v, n.Attrs)
+	case *Attr:
+		Walk(v, n.Name)
+		walkOpt(v, n.Args)
+
+	// Types
+	case *NamedType:
+		Walk(v, n.Name)
+	case *NullableType:
+		Walk(v, n.Type)
+	case *UnionType:
+		walkList(v, n.Types)
+	case *IntersectionType:
+		walkList(v, n.Types)
+	case *ParenType:
+		Walk(v, n.Type)
+
+	// Expressions
+	case *BadExpr, *Var, *Lit:
+		// nothing to do
+	case *VarVar:
+		Walk(v, n.X)
+	case *NameExpr:
+		Walk(v, n.Name)
+	case *ArrayLit:
+		walkList(v, n.Items)
+	case *ArrayItem:
+		walkOpt(v, n.Key)
+		Walk(v, n.Value)
+	case *ParenExpr:
+		Walk(v, n.X)
+	case *UnaryExpr:
+		Walk(v, n.X)
+	case *IncDecExpr:
+		Walk(v, n.X)
+	case *BinaryExpr:
+		Walk(v, n.X)
+		Walk(v, n.Y)
+	case *AssignExpr:
+		Walk(v, n.X)
+		Walk(v, n.Y)
+	case *TernaryExpr:
+		Walk(v, n.Cond)
+		walkOpt(v, n.Then)
+		Walk(v, n.Else)
+	case *CastExpr:
+		Walk(v, n.X)
+	case *InstanceofExpr:
+		Walk(v, n.X)
+		Walk(v, n.Class)
+	case *ArgList:
+		walkList(v, n.Args)
+	case *Arg:
+		walkOpt(v, n.Name)
+		Walk(v, n.Value)
+	case *CallExpr:
+		Walk(v, n.Fun)
+		Walk(v, n.Args)
+	case *PropExpr:
+		Walk(v, n.X)
+		Walk(v, n.Name)
+	case *StaticExpr:
+		Walk(v, n.Class)
+		Walk(v, n.Name)
+	case *BraceExpr:
+		Walk(v, n.X)
+	case *IndexExpr:
+		Walk(v, n.X)
+		walkOpt(v, n.Index)
+	case *NewExpr:
+		Walk(v, n.Class)
+		walkOpt(v, n.Args)
+	case *CloneExpr:
+		Walk(v, n.X)
+	case *PrintExpr:
+		Walk(v, n.X)
+	case *ThrowExpr:
+		Walk(v, n.X)
+	case *YieldExpr:
+		walkOpt(v, n.Key)
+		walkOpt(v, n.Value)
+	case *YieldFromExpr:
+		Walk(v, n.X)
+	case *IncludeExpr:
+		Walk(v, n.X)
+	case *ClosureExpr:
+		walkList(v, n.Attrs)
+		Walk(v, n.Params)
+		walkList(v, n.Uses)
+		walkOpt(v, n.Result)
+		Walk(v, n.Body)
+	case *ClosureUse:
+		Walk(v, n.Var)
+	case *ArrowFuncExpr:
+		walkList(v, n.Attrs)
+		Walk(v, n.Params)
+		walkOpt(v, n.Result)
+		Walk(v, n.Body)
+	case *MatchExpr:
+		Walk(v, n.Subject)
+		walkList(v, n.Arms)
+	case *MatchArm:
+		walkList(v, n.Conds)
+		Walk(v, n.Body)
+
+	// Statements
+	case *ExprStmt:
+		Walk(v, n.X)
+	case *EchoStmt:
+		walkList(v, n.Exprs)
+	case *InlineHTML, *EmptyStmt:
+		// nothing to do
+	case *BlockStmt:
+		walkList(v, n.Stmts)
+	case *IfStmt:
+		Walk(v, n.Cond)
+		Walk(v, n.Then)
+		walkOpt(v, n.Else)
+	case *WhileStmt:
+		Walk(v, n.Cond)
+		Walk(v, n.Body)
+	case *DoWhileStmt:
+		Walk(v, n.Body)
+		Walk(v, n.Cond)
+	case *ForStmt:
+		walkList(v, n.Init)
+		walkList(v, n.Cond)
+		walkList(v, n.Post)
+		Walk(v, n.Body)
+	case *ForeachStmt:
+		Walk(v, n.X)
+		walkOpt(v, n.Key)
+		Walk(v, n.Value)
+		Walk(v, n.Body)
+	case *SwitchStmt:
+		Walk(v, n.Tag)
+		walkList(v, n.Cases)
+	case *CaseClause:
+		walkOpt(v, n.Expr)
+		walkList(v, n.Body)
+	case *BranchStmt:
+		walkOpt(v, n.Depth)
+	case *ReturnStmt:
+		walkOpt(v, n.X)
+	case *GlobalStmt:
+		walkList(v, n.Vars)
+	case *StaticStmt:
+		walkList(v, n.Vars)
+	case *StaticVar:
+		Walk(v, n.Var)
+		walkOpt(v, n.Default)
+	case *UnsetStmt:
+		walkList(v, n.Exprs)
+	case *GotoStmt:
+		Walk(v, n.Label)
+	case *LabelStmt:
+		Walk(v, n.Label)
+	case *TryStmt:
+		Walk(v, n.Body)
+		walkList(v, n.Catches)
+		walkOpt(v, n.Finally)
+	case *CatchClause:
+		walkList(v, n.Types)
+		walkOpt(v, n.Var)
+		Walk(v, n.Body)
+	case *DeclareStmt:
+		walkList(v, n.Directives)
+		walkOpt(v, n.Body)
+	case *NamespaceStmt:
+		walkOpt(v, n.Name)
+		walkOpt(v, n.Body)
+	case *UseStmt:
+		walkOpt(v, n.Prefix)
+		walkList(v, n.Items)
+	case *UseItem:
+		Walk(v, n.Name)
+		walkOpt(v, n.Alias)
+	case *ConstStmt:
+		walkList(v, n.Items)
+	case *ConstItem:
+		Walk(v, n.Name)
+		Walk(v, n.Value)
+
+	// Declarations
+	case *FuncDecl:
+		walkList(v, n.Attrs)
+		Walk(v, n.Name)
+		Walk(v, n.Params)
+		walkOpt(v, n.Result)
+		Walk(v, n.Body)
+	case *ParamList:
+		walkList(v, n.Params)
+	case *Param:
+		walkList(v, n.Attrs)
+		walkOpt(v, n.Type)
+		Walk(v, n.Var)
+		walkOpt(v, n.Default)
+		walkList(v, n.Hooks)
+	case *ClassDecl:
+		walkList(v, n.Attrs)
+		walkOpt(v, n.Name)
+		walkOpt(v, n.Args)
+		walkOpt(v, n.EnumType)
+		walkList(v, n.Extends)
+		walkList(v, n.Implements)
+		walkList(v, n.Members)
+	case *MethodDecl:
+		walkList(v, n.Attrs)
+		Walk(v, n.Name)
+		Walk(v, n.Params)
+		walkOpt(v, n.Result)
+		walkOpt(v, n.Body)
+	case *PropertyDecl:
+		walkList(v, n.Attrs)
+		walkOpt(v, n.Type)
+		walkList(v, n.Props)
+		walkList(v, n.Hooks)
+	case *PropItem:
+		Walk(v, n.Var)
+		walkOpt(v, n.Default)
+	case *PropHook:
+		walkList(v, n.Attrs)
+		Walk(v, n.Name)
+		walkOpt(v, n.Params)
+		walkOpt(v, n.Body)
+	case *ClassConstDecl:
+		walkList(v, n.Attrs)
+		walkOpt(v, n.Type)
+		walkList(v, n.Items)
+	case *EnumCase:
+		walkList(v, n.Attrs)
+		Walk(v, n.Name)
+		walkOpt(v, n.Value)
+	case *TraitUse:
+		walkList(v, n.Traits)
+		walkList(v, n.Rules)
+	case *TraitRule:
+		walkOpt(v, n.Trait)
+		Walk(v, n.Method)
+		walkList(v, n.Insteadof)
+		walkOpt(v, n.Alias)
+
+	default:
+		panic(fmt.Sprintf("syntax.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+// walkOpt walks n unless it is nil.
+func walkOpt[N interface {
+	comparable
+	Node
+}](v Visitor, n N) {
+	var zero N
+	if n != zero {
+		Walk(v, n)
+	}
+}
+
+// walkList walks the non-nil nodes in list,
+// e.g. the items of an ArrayLit.
+func walkList[N interface {
+	comparable
+	Node
+}](v Visitor, list []N) {
+	for _, n := range list {
+		walkOpt(v, n)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a syntax tree in depth-first order: It starts
+// by calling f(node); node must not be nil. If f returns true,
+// Inspect invokes f recursively for each of the non-nil children
+// of node, followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
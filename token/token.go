@@ -6,6 +6,8 @@ import (
 	"io"
 	"strings"
 	"unicode/utf8"
+
+	"mibk.dev/phpfmt/phpver"
 )
 
 // runeScanner is the interface used by Scanner.r.
@@ -218,7 +220,7 @@ const (
 )
 
 type Scanner struct {
-	php74Compat bool
+	ver phpver.Version
 
 	r         runeScanner
 	state     uint
@@ -232,16 +234,18 @@ type Scanner struct {
 	lastLineLen int
 }
 
-func NewScanner(r io.Reader, php74Compat bool) *Scanner {
+// NewScanner returns a Scanner reading PHP code from r.
+// The code is scanned according to the PHP version ver.
+func NewScanner(r io.Reader, ver phpver.Version) *Scanner {
 	rs, ok := r.(runeScanner)
 	if !ok {
 		rs = bufio.NewReader(r)
 	}
 	return &Scanner{
-		php74Compat: php74Compat,
-		r:           rs,
-		line:        1,
-		col:         1,
+		ver:  ver,
+		r:    rs,
+		line: 1,
+		col:  1,
 	}
 }
 
@@ -398,7 +402,8 @@ func (s *Scanner) scanAny() (tok Token) {
 			return Token{Type: Quo}
 		}
 	case '#':
-		if !s.php74Compat && s.peek() == '[' {
+		// Before PHP 8.0, #[ started a comment, not an attribute.
+		if s.ver.AtLeast(phpver.PHP80) && s.peek() == '[' {
 			return Token{Type: Hash}
 		}
 		return s.scanLineComment("#")
@@ -581,8 +586,8 @@ func (s *Scanner) scanAny() (tok Token) {
 		return s.scanWhitespace()
 	case '\'':
 		return s.scanSingleQuoted()
-	case '"':
-		return s.scanDoubleQuoted()
+	case '"', '`':
+		return s.scanInterpolated(r)
 	default:
 		if isDigit(r) {
 			return s.scanNumber(r)
@@ -757,7 +762,9 @@ func (s *Scanner) scanSingleQuoted() Token {
 	}
 }
 
-func (s *Scanner) scanDoubleQuoted() Token {
+// scanInterpolated scans a double-quoted string, or a shell command
+// in backticks, which is interpolated the same way.
+func (s *Scanner) scanInterpolated(quote rune) Token {
 	var b strings.Builder
 	for {
 		r := s.read()
@@ -767,8 +774,8 @@ func (s *Scanner) scanDoubleQuoted() Token {
 			// Allow all escape sequences, even unknown ones.
 			// Be compatible with PHP for now.
 			b.WriteRune(s.read())
-		case '"':
-			return Token{Type: String, Text: `"` + b.String()}
+		case quote:
+			return Token{Type: String, Text: string(quote) + b.String()}
 		case eof:
 			return s.errorf("string not terminated")
 		}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"mibk.dev/phpfmt/phpver"
 	"mibk.dev/phpfmt/token"
 )
 
@@ -145,6 +146,17 @@ namespace /*block ?> */ DateTime/** comments*/;# another line comm? or?
 			{token.String, "\"\\n\\r\\t\\v\\e\\f\\$\\xED\\u{2030}\\%\"", pos("3:1")},
 			{token.EOF, "", pos("3:31")},
 		},
+	}, {
+		"shell commands",
+		"<?php `ls -l $dir`;`a\\`b`",
+		[]token.Token{
+			{token.OpenTag, "<?php", pos("1:1")},
+			{token.Whitespace, " ", pos("1:6")},
+			{token.String, "`ls -l $dir`", pos("1:7")},
+			{token.Semicolon, ";", pos("1:19")},
+			{token.String, "`a\\`b`", pos("1:20")},
+			{token.EOF, "", pos("1:26")},
+		},
 	}, {
 		"variables",
 		`<?php $žluťoučký;$$kůň;`,
@@ -622,12 +634,12 @@ true false null True
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			php74Compat := true
+			ver := phpver.PHP74
 			if strings.Contains(tt.name, "#[attr]") {
 				// A hack to switch mode.
-				php74Compat = false
+				ver = phpver.PHP80
 			}
-			sc := token.NewScanner(strings.NewReader(tt.input), php74Compat)
+			sc := token.NewScanner(strings.NewReader(tt.input), ver)
 
 			var got []token.Token
 			for {
@@ -694,7 +706,7 @@ nic
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			sc := token.NewScanner(strings.NewReader(tt.input), true)
+			sc := token.NewScanner(strings.NewReader(tt.input), phpver.PHP74)
 
 			for sc.Next().Type != token.EOF {
 			}
@@ -710,7 +722,7 @@ nic
 }
 
 func TestBadReader(t *testing.T) {
-	sc := token.NewScanner(new(badReader), true)
+	sc := token.NewScanner(new(badReader), phpver.PHP74)
 	for sc.Next().Type != token.EOF {
 	}
 	errStr := "<nil>"
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"mibk.dev/phpfmt/internal/ignore"
+)
+
+// defaultExcludes are excluded from directory walks
+// unless re-included by an -exclude !pattern.
+var defaultExcludes = []string{"vendor/"}
+
+// stringList is a flag.Value that collects repeated flags.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(s string) error { *l = append(*l, s); return nil }
+
+// walkFiles calls add for each PHP file found in paths, in order.
+// Directories are walked recursively, skipping the files ignored
+// by .gitignore files, the -exclude patterns, or the exclude
+// patterns of the configuration files. An error in a configuration
+// file is reported only once. If addDir is not nil,
+// it is called for each directory walked.
+func walkFiles(paths []string, add func(*fileJob), addDir func(string)) {
+	var configErrs []error
+	for _, filename := range paths {
+		fi, err := os.Stat(filename)
+		if err != nil {
+			add(&fileJob{path: filename, err: err})
+			continue
+		}
+
+		if !fi.IsDir() {
+			add(&fileJob{path: filename, lines: lines})
+			continue
+		}
+
+		root, err := filepath.Abs(filename)
+		if err != nil {
+			add(&fileJob{path: filename, err: err})
+			continue
+		}
+		excl := ignore.Parse(filepath.ToSlash(root), nil)
+		for _, pat := range defaultExcludes {
+			excl.Add(pat)
+		}
+		for _, pat := range excludes {
+			excl.Add(pat)
+		}
+		excluded := ignore.Stack{excl}
+		ign := newGitignores(root)
+
+		filepath.WalkDir(filename, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				add(&fileJob{path: path, err: err})
+				return nil
+			}
+			rel, err := filepath.Rel(filename, path)
+			if err != nil {
+				add(&fileJob{path: path, err: err})
+				return nil
+			}
+			abs := filepath.Join(root, rel)
+			if rel != "." {
+				cfg, err := findConfig(filepath.Dir(abs))
+				if err != nil {
+					// The error is cached, so report it only for
+					// the first file of the broken configuration.
+					if !slices.Contains(configErrs, err) {
+						configErrs = append(configErrs, err)
+						add(&fileJob{path: path, err: err})
+					}
+					return nil
+				}
+				name := filepath.ToSlash(abs)
+				if d.Name() == ".git" || excluded.Ignored(name, d.IsDir()) ||
+					cfg.exclude.Ignored(name, d.IsDir()) ||
+					ign.stack(filepath.Dir(abs)).Ignored(name, d.IsDir()) {
+					if d.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+			}
+			if d.IsDir() {
+				ign.load(abs)
+				if addDir != nil {
+					addDir(path)
+				}
+				return nil
+			}
+			switch filepath.Ext(d.Name()) {
+			default:
+				return nil
+			case ".php", ".phpt":
+			}
+
+			add(&fileJob{path: path})
+			return nil
+		})
+	}
+}
+
+// gitignores holds the patterns read from .gitignore files.
+type gitignores struct {
+	base  ignore.Stack            // .git/info/exclude
+	lists map[string]*ignore.List // by directory; nil if there are no patterns
+}
+
+// newGitignores loads the .gitignore files of the Git repository
+// containing root, from the top-level directory down to root.
+// The .gitignore files below root are loaded as they are walked.
+func newGitignores(root string) *gitignores {
+	g := &gitignores{lists: make(map[string]*ignore.List)}
+	var dirs []string
+	for dir := root; ; {
+		dirs = append(dirs, dir)
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			b, err := os.ReadFile(filepath.Join(dir, ".git", "info", "exclude"))
+			if err == nil {
+				g.base = ignore.Stack{ignore.Parse(filepath.ToSlash(dir), b)}
+			}
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			// Not in a repository.
+			dirs = dirs[:1]
+			break
+		}
+		dir = parent
+	}
+	for _, dir := range slices.Backward(dirs[1:]) {
+		g.load(dir)
+	}
+	return g
+}
+
+// load reads the .gitignore file in dir, if there is one.
+func (g *gitignores) load(dir string) {
+	b, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		g.lists[dir] = nil
+		return
+	}
+	g.lists[dir] = ignore.Parse(filepath.ToSlash(dir), b)
+}
+
+// stack returns the patterns that apply to the files in dir.
+func (g *gitignores) stack(dir string) ignore.Stack {
+	var s ignore.Stack
+	for {
+		l, ok := g.lists[dir]
+		if !ok {
+			break
+		}
+		if l != nil {
+			s = append(s, l)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	slices.Reverse(s)
+	return append(slices.Clip(g.base), s...)
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+const (
+	// debounce is how long to wait for more changes of files
+	// before formatting them, e.g. while an editor saves them.
+	debounce = 100 * time.Millisecond
+
+	// pollInterval is how often the files are checked
+	// if they cannot be watched otherwise.
+	pollInterval = time.Second
+)
+
+// A watchEvent reports a changed file, or a created directory.
+type watchEvent struct {
+	path  string
+	isDir bool
+}
+
+// A dirWatcher reports changes of files in the directories added to it.
+// It does not watch the subdirectories; the created ones are reported
+// and they need to be added separately.
+type dirWatcher interface {
+	add(dir string) error
+	events() <-chan watchEvent
+}
+
+// watchFiles watches the PHP files found in paths (as walkFiles finds
+// them), and formats the files as they are modified, until the program
+// is stopped. Files whose content has already been processed are
+// skipped, so that writing the formatted result is not taken for
+// another modification. Changes of the configuration and Composer
+// files apply to the later modifications. The cache c may be nil.
+func watchFiles(paths []string, c *cache) {
+	w := &watchState{paths: paths, cache: c, seen: make(map[string][sha256.Size]byte)}
+	dw, err := newDirWatcher()
+	if err != nil {
+		if *verbose {
+			log.Printf("watching by polling: %v", err)
+		}
+		dw = newPoller(w.scan, pollInterval)
+	}
+	w.watch(dw, nil)
+}
+
+// watch formats the files as dw reports their changes,
+// until stop is closed.
+func (w *watchState) watch(dw dirWatcher, stop <-chan struct{}) {
+	w.dw = dw
+	w.rescan()
+
+	pending := make(map[string]bool)
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case ev := <-dw.events():
+			path := filepath.Clean(ev.path)
+			switch filepath.Base(path) {
+			case configName, "composer.json":
+				// The configuration of the files below has changed,
+				// e.g. the PHP version, or the excluded files.
+				if dir, err := filepath.Abs(filepath.Dir(path)); err == nil {
+					forgetConfigs(dir)
+					forgetPHPVersions(dir)
+				}
+				w.stale = true
+				timer.Reset(debounce)
+				continue
+			}
+			if !w.known[path] && !w.walked[filepath.Dir(path)] {
+				// E.g. a file next to a file given explicitly,
+				// whose directory is watched.
+				continue
+			}
+			if ev.isDir {
+				w.stale = true
+			} else {
+				switch filepath.Ext(path) {
+				default:
+					continue
+				case ".php", ".phpt":
+				}
+				pending[path] = true
+			}
+			timer.Reset(debounce)
+		case <-timer.C:
+			rescanned := w.stale
+			if w.stale {
+				w.rescan()
+			}
+			for _, path := range slices.Sorted(maps.Keys(pending)) {
+				if !w.known[path] && !rescanned {
+					// Maybe a new file.
+					w.rescan()
+					rescanned = true
+				}
+				if w.known[path] {
+					w.format(path)
+				}
+			}
+			clear(pending)
+		}
+	}
+}
+
+type watchState struct {
+	paths  []string
+	cache  *cache
+	dw     dirWatcher
+	known  map[string]bool // the files to format
+	walked map[string]bool // the directories walked to find them
+	stale  bool            // known needs a rescan
+
+	// seen holds the hashes of the last content
+	// of the files that was formatted or written.
+	seen map[string][sha256.Size]byte
+}
+
+// rescan finds the files to format again, and watches all the
+// directories walked, and the directories of the files in paths.
+func (w *watchState) rescan() {
+	w.known = make(map[string]bool)
+	w.walked = make(map[string]bool)
+	w.scan(func(j *fileJob) {
+		if j.err != nil {
+			fmt.Fprintln(os.Stderr, j.err)
+			return
+		}
+		w.known[filepath.Clean(j.path)] = true
+	}, func(dir string) {
+		w.walked[filepath.Clean(dir)] = true
+		w.watchDir(dir)
+	})
+	for _, path := range w.paths {
+		if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+			// Only directories can be watched; the changes
+			// of the other files in it are left out.
+			w.watchDir(filepath.Dir(path))
+		}
+	}
+	w.stale = false
+}
+
+func (w *watchState) watchDir(dir string) {
+	if err := w.dw.add(dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+func (w *watchState) scan(add func(*fileJob), addDir func(string)) {
+	walkFiles(w.paths, add, addDir)
+}
+
+// format formats the file path, unless its content has already been seen.
+func (w *watchState) format(path string) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return
+	}
+	sum := sha256.Sum256(src)
+	if w.seen[path] == sum {
+		return
+	}
+
+	j := &fileJob{path: path, cache: w.cache, done: make(chan struct{})}
+	j.run()
+	os.Stderr.Write(j.log.Bytes())
+	os.Stdout.Write(j.out.Bytes())
+	if j.err != nil {
+		fmt.Fprintln(os.Stderr, j.err)
+	}
+	if j.changed && *inPlace {
+		if *verbose {
+			log.Printf("formatted %s", path)
+		}
+		// Don't take our own write for a modification.
+		if src, err = os.ReadFile(path); err != nil {
+			return
+		}
+		sum = sha256.Sum256(src)
+	}
+	w.seen[path] = sum
+}
+
+// A poller is a dirWatcher that periodically checks
+// the modification times of all the files found by scan,
+// and of the configuration files in their directories.
+// The directories added to it are ignored.
+type poller struct {
+	scan     func(add func(*fileJob), addDir func(string))
+	interval time.Duration
+	c        chan watchEvent
+}
+
+type fileStamp struct {
+	mod  time.Time
+	size int64
+}
+
+// newPoller returns a poller that checks the files every interval.
+// The files are first checked before newPoller returns, so that
+// the later changes are reported.
+func newPoller(scan func(add func(*fileJob), addDir func(string)), interval time.Duration) *poller {
+	p := &poller{scan: scan, interval: interval, c: make(chan watchEvent)}
+	last, _ := p.check(nil)
+	go p.run(last)
+	return p
+}
+
+func (p *poller) add(dir string) error      { return nil }
+func (p *poller) events() <-chan watchEvent { return p.c }
+
+func (p *poller) run(last map[string]fileStamp) {
+	for {
+		time.Sleep(p.interval)
+		var changed []string
+		last, changed = p.check(last)
+		for _, path := range changed {
+			p.c <- watchEvent{path: path}
+		}
+	}
+}
+
+// check returns the stamps of the files, and the files
+// that changed since the last stamps.
+func (p *poller) check(last map[string]fileStamp) (stamps map[string]fileStamp, changed []string) {
+	stamps = make(map[string]fileStamp)
+	stamp := func(path string) {
+		if _, ok := stamps[path]; ok {
+			return
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return
+		}
+		st := fileStamp{fi.ModTime(), fi.Size()}
+		stamps[path] = st
+		if old, ok := last[path]; last != nil && (!ok || old != st) {
+			changed = append(changed, path)
+		}
+	}
+	stampConfigs := func(dir string) {
+		stamp(filepath.Join(dir, configName))
+		stamp(filepath.Join(dir, "composer.json"))
+	}
+	p.scan(func(j *fileJob) {
+		if j.err != nil {
+			return
+		}
+		stamp(j.path)
+		stampConfigs(filepath.Dir(j.path))
+	}, stampConfigs)
+	return stamps, changed
+}
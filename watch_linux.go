@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// An inotify is a dirWatcher using the Linux inotify API.
+type inotify struct {
+	fd int
+	c  chan watchEvent
+
+	mu   sync.Mutex
+	dirs map[int32]string // by watch descriptor
+}
+
+func newDirWatcher() (dirWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	w := &inotify{fd: fd, c: make(chan watchEvent), dirs: make(map[int32]string)}
+	go w.run()
+	return w, nil
+}
+
+func (w *inotify) events() <-chan watchEvent { return w.c }
+
+func (w *inotify) add(dir string) error {
+	const mask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE | syscall.IN_ONLYDIR
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, mask)
+	if err != nil {
+		return fmt.Errorf("watching %s: %v", dir, err)
+	}
+	w.mu.Lock()
+	w.dirs[int32(wd)] = dir
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *inotify) run() {
+	var buf [64 * (syscall.SizeofInotifyEvent + syscall.NAME_MAX + 1)]byte
+	for {
+		n, err := syscall.Read(w.fd, buf[:])
+		if err == syscall.EINTR {
+			continue
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, os.NewSyscallError("read inotify", err))
+			return
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			name := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(ev.Len)]
+			off += syscall.SizeofInotifyEvent + int(ev.Len)
+
+			w.mu.Lock()
+			dir, ok := w.dirs[ev.Wd]
+			if ev.Mask&syscall.IN_IGNORED != 0 {
+				delete(w.dirs, ev.Wd)
+			}
+			w.mu.Unlock()
+			if !ok || len(name) == 0 {
+				continue
+			}
+			path := filepath.Join(dir, string(name[:clen(name)]))
+			switch isDir := ev.Mask&syscall.IN_ISDIR != 0; {
+			case isDir && ev.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+				w.c <- watchEvent{path: path, isDir: true}
+			case !isDir && ev.Mask&(syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO) != 0:
+				w.c <- watchEvent{path: path}
+			}
+		}
+	}
+}
+
+// clen returns the length of the NUL-terminated name.
+func clen(name []byte) int {
+	for i, b := range name {
+		if b == 0 {
+			return i
+		}
+	}
+	return len(name)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	type newWatcher func(w *watchState) (dirWatcher, error)
+	watchers := map[string]newWatcher{
+		"poller": func(w *watchState) (dirWatcher, error) {
+			return newPoller(w.scan, 10*time.Millisecond), nil
+		},
+	}
+	if runtime.GOOS == "linux" {
+		watchers["native"] = func(*watchState) (dirWatcher, error) { return newDirWatcher() }
+	}
+
+	defer func(old bool) { *inPlace = old }(*inPlace)
+	*inPlace = true
+	const src, want = "<?php\n\n$x=2;\n", "<?php\n\n$x = 2;\n"
+
+	for name, newWatcher := range watchers {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			sub := filepath.Join(dir, "sub")
+			if err := os.Mkdir(sub, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			// A file given explicitly, and a file found by walking sub.
+			a, b := filepath.Join(dir, "a.php"), filepath.Join(sub, "b.php")
+			other := filepath.Join(dir, "other.php")
+			for _, f := range []string{a, b, other} {
+				if err := os.WriteFile(f, []byte("<?php\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			w := &watchState{
+				paths: []string{a, sub},
+				cache: &cache{dir: t.TempDir()},
+				seen:  make(map[string][sha256.Size]byte),
+			}
+			dw, err := newWatcher(w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				w.watch(dw, stop)
+				close(done)
+			}()
+			defer func() {
+				close(stop)
+				<-done
+			}()
+
+			// Give the native watcher time to watch the directories.
+			time.Sleep(50 * time.Millisecond)
+			for _, f := range []string{a, b, other} {
+				if err := os.WriteFile(f, []byte(src), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, f := range []string{a, b} {
+				waitFor(t, f, want)
+			}
+			// Give phpfmt a chance to format it by mistake.
+			time.Sleep(3 * debounce)
+			if got, _ := os.ReadFile(other); string(got) != src {
+				t.Errorf("%s: got %q, want it left alone", other, got)
+			}
+
+			// A change of the configuration applies to the next writes.
+			config := filepath.Join(sub, configName)
+			if err := os.WriteFile(config, []byte(`{"lowercaseKeywords": false}`), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(3 * debounce)
+			if err := os.WriteFile(b, []byte("<?php\n\nECHO  1;\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			waitFor(t, b, "<?php\n\nECHO 1;\n")
+		})
+	}
+}
+
+// waitFor waits until the file has the content want.
+func waitFor(t *testing.T, file, want string) {
+	t.Helper()
+	var got []byte
+	for range 100 {
+		var err error
+		if got, err = os.ReadFile(file); err != nil {
+			t.Fatal(err)
+		}
+		if string(got) == want {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Errorf("%s: got %q, want %q", file, got, want)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// writeFile replaces the content of the file path with data atomically:
+// data is written to a temporary file in the same directory, which is
+// then renamed over the original. The mode and, where possible, the
+// ownership of the original are preserved. If path is a symbolic link,
+// the file it points to is replaced, not the link.
+//
+// Note that the original file is replaced by a new one, so hard links
+// to it keep the old content.
+func writeFile(path string, data []byte) (err error) {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return &fs.PathError{Op: "write", Path: path, Err: fs.ErrInvalid}
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".phpfmt-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// The temporary file is created with mode 0600.
+	if err := os.Chmod(f.Name(), fi.Mode()&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)); err != nil {
+		return err
+	}
+	chownLike(f.Name(), fi)
+	return os.Rename(f.Name(), target)
+}
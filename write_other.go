@@ -0,0 +1,7 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+func chownLike(name string, fi fs.FileInfo) {}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package main
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// chownLike tries to give the file name the owner and group of fi.
+// If that is not permitted (usually unless running as root),
+// the file stays owned by the current user.
+func chownLike(name string, fi fs.FileInfo) {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		os.Chown(name, int(st.Uid), int(st.Gid))
+	}
+}